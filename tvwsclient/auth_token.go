@@ -1,44 +1,155 @@
 package tvwsclient
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"strings"
 	"sync"
 	"time"
 )
 
-// AuthTokenManager is a singleton that manages the auth token
+// AnonymousAuthToken is the token TradingView accepts for unauthenticated (delayed data) sessions
+const AnonymousAuthToken = "unauthorized_user_token"
+
+// AuthTokenManager manages the auth token used to authenticate WebSocket sessions.
+// Each Client can use its own manager through WithTokenProvider; the package-level
+// instance set up by InitAuthTokenManager is only used as a default.
 type AuthTokenManager struct {
 	authToken string
 	mu        sync.RWMutex
 	client    *TVHttpClient
+
+	refreshMu         sync.Mutex    // guards refresh
+	refresh           *tokenRefresh // token fetch in progress, nil when idle
+	retryAttempts     int
+	retryBaseDelay    time.Duration
+	retryMaxDelay     time.Duration
+	anonymousFallback bool
+
+	listenersMu sync.Mutex
+	listeners   map[int]func(TokenRotation)
+	listenerSeq int
+}
+
+// TokenRotation describes a freshly fetched auth token
+type TokenRotation struct {
+	Token     string
+	ExpiresAt time.Time // zero when the token carries no exp claim
+	RotatedAt time.Time
+}
+
+// tokenRefresh is a token fetch shared by concurrent callers
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+var _ AuthTokenManagerInterface = (*AuthTokenManager)(nil)
+
+// defaultTokenRefreshLead is how long before expiry clients renew the token
+// of their provider
+const defaultTokenRefreshLead = 5 * time.Minute
+
+// AuthTokenOption configures an AuthTokenManager
+type AuthTokenOption func(*AuthTokenManager)
+
+// WithTokenRetry sets how many times a quote token fetch is attempted and the
+// initial backoff delay, which doubles after every failed attempt
+func WithTokenRetry(attempts int, baseDelay time.Duration) AuthTokenOption {
+	return func(m *AuthTokenManager) {
+		if attempts < 1 {
+			attempts = 1
+		}
+		m.retryAttempts = attempts
+		m.retryBaseDelay = baseDelay
+	}
+}
+
+// WithAnonymousFallback makes the manager fall back to AnonymousAuthToken when
+// no valid token can be fetched, instead of failing the connection
+func WithAnonymousFallback() AuthTokenOption {
+	return func(m *AuthTokenManager) {
+		m.anonymousFallback = true
+	}
 }
 
 var (
-	instance *AuthTokenManager
-	once     sync.Once
+	instanceMu sync.RWMutex // guards instance
+	instance   *AuthTokenManager
+
+	initMu      sync.Mutex // serializes InitDefaultAuthTokenManager
+	initialized bool       // set once the first token was fetched
 )
 
+// NewAuthTokenManager creates a token manager that fetches quote tokens with client.
+// A nil client yields a manager that only serves tokens set through SetToken.
+func NewAuthTokenManager(client *TVHttpClient, opts ...AuthTokenOption) *AuthTokenManager {
+	m := &AuthTokenManager{
+		client:         client,
+		retryAttempts:  3,
+		retryBaseDelay: 500 * time.Millisecond,
+		retryMaxDelay:  30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// InitAuthTokenManager initializes the package-level default AuthTokenManager,
+// used by clients created without WithTokenProvider. Token fetch failures are
+// logged and retried when the first connection is made.
 func InitAuthTokenManager(client *TVHttpClient) {
-	once.Do(func() {
-		instance = &AuthTokenManager{
-			client: client,
-		}
-		token, err := instance.client.GetQuoteToken()
-		if err != nil {
-			panic(err)
+	if err := InitDefaultAuthTokenManager(client); err != nil {
+		slog.Error("failed to fetch initial auth token", "error", err)
+	}
+}
+
+// InitDefaultAuthTokenManager initializes the package-level default
+// AuthTokenManager and fetches the first token, returning any fetch error.
+// The manager is created by the first call; later calls retry the first
+// fetch until it succeeded and have no effect afterwards.
+func InitDefaultAuthTokenManager(client *TVHttpClient, opts ...AuthTokenOption) error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initialized {
+		return nil
+	}
+
+	m := defaultAuthTokenManager()
+	if m == nil {
+		m = NewAuthTokenManager(client, opts...)
+		instanceMu.Lock()
+		instance = m
+		instanceMu.Unlock()
+	}
+	if m.client != nil {
+		if err := m.RefreshToken(); err != nil {
+			return err
 		}
-		instance.SetToken(token)
-	})
+	}
+	initialized = true
+	return nil
 }
 
-// GetAuthTokenManager returns the singleton instance of AuthTokenManager
+// GetAuthTokenManager returns the package-level default AuthTokenManager
 func GetAuthTokenManager() *AuthTokenManager {
-	if instance == nil {
+	m := defaultAuthTokenManager()
+	if m == nil {
 		panic("AuthTokenManager not initialized")
 	}
+	return m
+}
+
+// defaultAuthTokenManager returns the package-level default AuthTokenManager,
+// or nil before InitDefaultAuthTokenManager was called
+func defaultAuthTokenManager() *AuthTokenManager {
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
 	return instance
 }
 
@@ -49,44 +160,303 @@ func (m *AuthTokenManager) SetToken(token string) {
 	m.authToken = token
 }
 
-// GetToken returns the current auth token, checking if it needs to be updated
+// GetToken returns the current auth token, refreshing it if needed. Fetch
+// failures are logged and the current, possibly stale, token is returned;
+// use FetchToken to handle them.
 func (m *AuthTokenManager) GetToken() string {
-	// First check with read lock
+	token, err := m.FetchToken()
+	if err != nil {
+		slog.Error("Failed to get quote token", "error", err)
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.authToken
+	}
+	return token
+}
+
+// FetchToken returns a valid auth token, fetching a new one when the current
+// token is empty or expired
+func (m *AuthTokenManager) FetchToken() (string, error) {
+	return m.FetchTokenContext(context.Background())
+}
+
+// FetchTokenContext is like FetchToken, but stops waiting for the token and
+// backing off between retries when ctx is cancelled
+func (m *AuthTokenManager) FetchTokenContext(ctx context.Context) (string, error) {
+	// Fast path with read lock
 	m.mu.RLock()
-	if m.authToken != "" && !m.CheckAuthTokenExpired() {
-		token := m.authToken
-		m.mu.RUnlock()
-		return token
+	token := m.authToken
+	m.mu.RUnlock()
+	if token != "" && (m.client == nil || !tokenExpired(token)) {
+		return token, nil
+	}
+
+	if m.client == nil {
+		if m.anonymousFallback {
+			return AnonymousAuthToken, nil
+		}
+		return "", WrapAuthError("auth_token.fetch", errors.New("no token set and no http client configured"))
+	}
+
+	token, err := m.refreshToken(ctx, false)
+	if err != nil {
+		if m.anonymousFallback {
+			slog.Warn("falling back to anonymous auth token", "error", err)
+			return AnonymousAuthToken, nil
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// RefreshToken fetches a new quote token regardless of the current token's expiry
+func (m *AuthTokenManager) RefreshToken() error {
+	return m.RefreshTokenContext(context.Background())
+}
+
+// RefreshTokenContext is like RefreshToken, but stops waiting for the token
+// and backing off between retries when ctx is cancelled
+func (m *AuthTokenManager) RefreshTokenContext(ctx context.Context) error {
+	if m.client == nil {
+		return WrapAuthError("auth_token.refresh", errors.New("no http client configured"))
+	}
+	_, err := m.refreshToken(ctx, true)
+	return err
+}
+
+// refreshToken fetches a new token, joining a fetch already in progress.
+// Unless force is set, a valid token stored in the meantime is returned
+// instead. Rotation listeners are notified once the fetch is finished.
+func (m *AuthTokenManager) refreshToken(ctx context.Context, force bool) (string, error) {
+	for {
+		m.refreshMu.Lock()
+		if !force {
+			// Another caller may have refreshed the token already
+			m.mu.RLock()
+			token := m.authToken
+			m.mu.RUnlock()
+			if token != "" && !tokenExpired(token) {
+				m.refreshMu.Unlock()
+				return token, nil
+			}
+		}
+
+		if r := m.refresh; r != nil {
+			m.refreshMu.Unlock()
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return "", WrapAuthError("auth_token.fetch", ctx.Err())
+			}
+			if r.err != nil && isContextError(r.err) && ctx.Err() == nil {
+				// The caller that fetched gave up, try again with our context
+				continue
+			}
+			return r.token, r.err
+		}
+
+		r := &tokenRefresh{done: make(chan struct{})}
+		m.refresh = r
+		m.refreshMu.Unlock()
+
+		r.token, r.err = m.fetchWithRetry(ctx)
+		if r.err == nil {
+			m.SetToken(r.token)
+		}
+		m.refreshMu.Lock()
+		m.refresh = nil
+		m.refreshMu.Unlock()
+		close(r.done)
+
+		if r.err != nil {
+			return "", r.err
+		}
+		m.notifyRotation(r.token)
+		return r.token, nil
+	}
+}
+
+// notifyRotation notifies rotation listeners of a freshly fetched token
+func (m *AuthTokenManager) notifyRotation(token string) {
+	rotation := TokenRotation{
+		Token:     token,
+		RotatedAt: time.Now(),
+	}
+	if exp, ok := tokenExpiry(token); ok {
+		rotation.ExpiresAt = exp
+	}
+
+	m.listenersMu.Lock()
+	listeners := make([]func(TokenRotation), 0, len(m.listeners))
+	for _, listener := range m.listeners {
+		listeners = append(listeners, listener)
+	}
+	m.listenersMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(rotation)
+	}
+}
+
+// OnTokenRotated registers a listener called every time a new token is fetched.
+// The returned function removes the listener.
+func (m *AuthTokenManager) OnTokenRotated(listener func(TokenRotation)) func() {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	if m.listeners == nil {
+		m.listeners = make(map[int]func(TokenRotation))
 	}
+	m.listenerSeq++
+	id := m.listenerSeq
+	m.listeners[id] = listener
+
+	return func() {
+		m.listenersMu.Lock()
+		defer m.listenersMu.Unlock()
+		delete(m.listeners, id)
+	}
+}
+
+// StartAutoRefresh refreshes the token in the background lead before its exp
+// claim, or halfway through its remaining lifetime when that is shorter than
+// lead, until ctx is cancelled. Rotation listeners are notified on every refresh.
+// Several refreshers may run on one manager; a token renewed by one of them is
+// not fetched again by the others. Managers without an http client are not refreshed.
+func (m *AuthTokenManager) StartAutoRefresh(ctx context.Context, lead time.Duration) {
+	if m.client == nil {
+		slog.Debug("auto refresh not started, no http client configured")
+		return
+	}
+	go func() {
+		for {
+			timer := time.NewTimer(m.nextRefreshIn(lead))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if !m.refreshDue(lead) {
+				continue
+			}
+			if err := m.RefreshTokenContext(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("proactive auth token refresh failed", "error", err)
+			}
+		}
+	}()
+}
+
+// refreshDue reports whether the current token is missing or within lead of its expiry
+func (m *AuthTokenManager) refreshDue(lead time.Duration) bool {
+	m.mu.RLock()
+	token := m.authToken
 	m.mu.RUnlock()
 
-	// If token is empty or expired, acquire write lock
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	exp, ok := tokenExpiry(token)
+	return !ok || time.Until(exp) <= lead
+}
+
+// nextRefreshIn returns how long to wait before refreshing the current token
+func (m *AuthTokenManager) nextRefreshIn(lead time.Duration) time.Duration {
+	m.mu.RLock()
+	token := m.authToken
+	m.mu.RUnlock()
+
+	exp, ok := tokenExpiry(token)
+	if !ok {
+		// No usable token yet, try again after the maximum backoff delay
+		return m.retryMaxDelay
+	}
+	remaining := time.Until(exp)
+	if remaining <= 0 {
+		// Refreshing failed until the token expired, keep trying slowly
+		return m.retryMaxDelay
+	}
+	if wait := remaining - lead; wait > 0 {
+		return wait
+	}
+	// Tokens living shorter than lead are renewed halfway through their
+	// remaining lifetime rather than on every tick
+	wait := remaining / 2
+	if wait < m.retryBaseDelay {
+		wait = m.retryBaseDelay
+	}
+	return wait
+}
 
-	// Double-check condition after acquiring write lock
-	if m.authToken == "" || m.CheckAuthTokenExpired() {
-		token, err := m.client.GetQuoteToken()
-		if err != nil {
-			slog.Error("Failed to get quote token", "error", err)
-			panic(err)
+// fetchWithRetry requests a quote token, backing off exponentially between
+// attempts until ctx is cancelled
+func (m *AuthTokenManager) fetchWithRetry(ctx context.Context) (string, error) {
+	delay := m.retryBaseDelay
+	var lastErr error
+	for attempt := 1; attempt <= m.retryAttempts; attempt++ {
+		token, err := m.client.GetQuoteTokenContext(ctx)
+		if err == nil {
+			return token, nil
+		}
+		lastErr = err
+
+		// Rejected credentials will not succeed on a second try
+		if attempt == m.retryAttempts || !IsRetryableError(err) || ctx.Err() != nil {
+			break
+		}
+		slog.Warn("quote token fetch failed, retrying",
+			"attempt", attempt,
+			"max_attempts", m.retryAttempts,
+			"delay", delay,
+			"error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", WrapAuthError("auth_token.fetch", ctx.Err())
+		case <-timer.C:
+		}
+		delay *= 2
+		if delay > m.retryMaxDelay {
+			delay = m.retryMaxDelay
 		}
-		m.authToken = token // Direct assignment since we already have the write lock
 	}
-	return m.authToken
+	return "", WrapAuthError("auth_token.fetch", lastErr)
+}
+
+// isContextError reports whether err was caused by a cancelled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
+// CheckAuthTokenExpired reports whether the current token is missing, malformed or about to expire
 func (m *AuthTokenManager) CheckAuthTokenExpired() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tokenExpired(m.authToken)
+}
+
+// tokenExpired reports whether a JWT is malformed or past its exp claim, allowing five minutes of clock skew
+func tokenExpired(token string) bool {
+	exp, ok := tokenExpiry(token)
+	if !ok {
+		return true
+	}
+
+	// Check if token is expired
+	return time.Now().Add(-5*time.Minute).Unix() >= exp.Unix()
+}
+
+// tokenExpiry returns the exp claim of a JWT
+func tokenExpiry(token string) (time.Time, bool) {
 	// Split the JWT token into parts
-	parts := strings.Split(m.authToken, ".")
+	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return true
+		return time.Time{}, false
 	}
 
 	// Decode the payload (second part)
 	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return true
+		return time.Time{}, false
 	}
 
 	// Parse the payload
@@ -94,9 +464,7 @@ func (m *AuthTokenManager) CheckAuthTokenExpired() bool {
 		Exp int64 `json:"exp"`
 	}
 	if err := json.Unmarshal(payload, &claims); err != nil {
-		return true
+		return time.Time{}, false
 	}
-
-	// Check if token is expired
-	return time.Now().Add(-5*time.Minute).Unix() >= claims.Exp
+	return time.Unix(claims.Exp, 0), true
 }
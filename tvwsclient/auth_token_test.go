@@ -1,15 +1,23 @@
 package tvwsclient
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestAuthTokenManager(t *testing.T) {
 
 	// Test token operations
 	t.Run("token operations", func(t *testing.T) {
-		manager := GetAuthTokenManager()
+		manager := NewAuthTokenManager(nil)
 
 		// Test initial state
 		if token := manager.GetToken(); token != "" {
@@ -24,8 +32,9 @@ func TestAuthTokenManager(t *testing.T) {
 		}
 	})
 
-	// Test singleton pattern
+	// Test singleton pattern of the package-level default
 	t.Run("singleton pattern", func(t *testing.T) {
+		InitAuthTokenManager(nil)
 		manager1 := GetAuthTokenManager()
 		manager2 := GetAuthTokenManager()
 
@@ -34,9 +43,228 @@ func TestAuthTokenManager(t *testing.T) {
 		}
 	})
 
+	// Test that managers created with NewAuthTokenManager are independent
+	t.Run("instance-scoped managers", func(t *testing.T) {
+		manager1 := NewAuthTokenManager(nil)
+		manager2 := NewAuthTokenManager(nil)
+		manager1.SetToken("account_1")
+		manager2.SetToken("account_2")
+
+		if token := manager1.GetToken(); token != "account_1" {
+			t.Errorf("manager1.GetToken() = %v, want account_1", token)
+		}
+		if token := manager2.GetToken(); token != "account_2" {
+			t.Errorf("manager2.GetToken() = %v, want account_2", token)
+		}
+		if err := manager1.RefreshToken(); !IsAuthError(err) {
+			t.Errorf("RefreshToken() without http client error = %v, want auth error", err)
+		}
+	})
+
+	// Test that token fetch failures are returned instead of panicking
+	t.Run("fetch failures", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		baseURL := server.URL
+		server.Close() // connections to baseURL are now refused
+
+		httpClient := NewTVHttpClient(baseURL, "device", "session", "sign")
+
+		manager := NewAuthTokenManager(httpClient, WithTokenRetry(2, time.Millisecond))
+		if _, err := manager.FetchToken(); !IsAuthError(err) {
+			t.Errorf("FetchToken() error = %v, want auth error", err)
+		}
+		if err := manager.RefreshToken(); !IsAuthError(err) {
+			t.Errorf("RefreshToken() error = %v, want auth error", err)
+		}
+		if token := manager.GetToken(); token != "" {
+			t.Errorf("GetToken() = %v, want empty token", token)
+		}
+
+		fallback := NewAuthTokenManager(httpClient, WithTokenRetry(1, 0), WithAnonymousFallback())
+		token, err := fallback.FetchToken()
+		if err != nil || token != AnonymousAuthToken {
+			t.Errorf("FetchToken() = %v, %v, want %v, nil", token, err, AnonymousAuthToken)
+		}
+	})
+
+	// Test proactive refresh and rotation listeners
+	t.Run("auto refresh", func(t *testing.T) {
+		freshToken := testJWT(time.Now().Add(time.Hour))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"` + freshToken + `"`))
+		}))
+		defer server.Close()
+
+		manager := NewAuthTokenManager(NewTVHttpClient(server.URL, "device", "session", "sign"))
+		manager.SetToken(testJWT(time.Now().Add(2 * time.Second)))
+
+		rotations := make(chan TokenRotation, 1)
+		unsubscribe := manager.OnTokenRotated(func(rotation TokenRotation) {
+			rotations <- rotation
+		})
+		defer unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		manager.StartAutoRefresh(ctx, time.Second)
+
+		select {
+		case rotation := <-rotations:
+			if rotation.Token != freshToken {
+				t.Errorf("rotated token = %v, want %v", rotation.Token, freshToken)
+			}
+			if rotation.ExpiresAt.Before(time.Now().Add(59 * time.Minute)) {
+				t.Errorf("rotation ExpiresAt = %v, want about an hour from now", rotation.ExpiresAt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("token was not refreshed before expiry")
+		}
+
+		if token := manager.GetToken(); token != freshToken {
+			t.Errorf("GetToken() = %v, want the refreshed token", token)
+		}
+	})
+
+	// Test that tokens living shorter than the lead are not refetched every second
+	t.Run("auto refresh of short-lived tokens", func(t *testing.T) {
+		var fetches atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			w.Write([]byte(`"` + testJWT(time.Now().Add(time.Minute)) + `"`))
+		}))
+		defer server.Close()
+
+		manager := NewAuthTokenManager(NewTVHttpClient(server.URL, "device", "session", "sign"))
+		manager.SetToken(testJWT(time.Now().Add(2 * time.Second)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		manager.StartAutoRefresh(ctx, defaultTokenRefreshLead)
+
+		time.Sleep(3 * time.Second)
+		if got := fetches.Load(); got != 1 {
+			t.Errorf("fetched %d tokens in 3s, want 1 for a token expiring in 2s", got)
+		}
+	})
+
+	// Test that a failed first fetch of the default manager can be retried
+	t.Run("default manager init retry", func(t *testing.T) {
+		resetDefaultAuthTokenManager(t)
+
+		freshToken := testJWT(time.Now().Add(time.Hour))
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`"` + freshToken + `"`))
+		}))
+		defer server.Close()
+
+		httpClient := NewTVHttpClient(server.URL, "device", "session", "sign")
+		if err := InitDefaultAuthTokenManager(httpClient, WithTokenRetry(1, 0)); !IsAuthError(err) {
+			t.Fatalf("first InitDefaultAuthTokenManager() error = %v, want auth error", err)
+		}
+		if err := InitDefaultAuthTokenManager(httpClient, WithTokenRetry(1, 0)); err != nil {
+			t.Fatalf("second InitDefaultAuthTokenManager() error = %v", err)
+		}
+		if token := GetAuthTokenManager().GetToken(); token != freshToken {
+			t.Errorf("GetToken() = %v, want the fetched token", token)
+		}
+		if err := InitDefaultAuthTokenManager(httpClient); err != nil || calls.Load() != 2 {
+			t.Errorf("InitDefaultAuthTokenManager() after success = %v with %d fetches, want nil with 2", err, calls.Load())
+		}
+	})
+
+	// Test that retry backoff stops when the context is cancelled
+	t.Run("cancelled backoff", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		manager := NewAuthTokenManager(NewTVHttpClient(server.URL, "device", "session", "sign"),
+			WithTokenRetry(5, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := manager.FetchTokenContext(ctx)
+		if !IsAuthError(err) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("FetchTokenContext() error = %v, want auth error wrapping context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("FetchTokenContext() took %v, want it to stop with the context", elapsed)
+		}
+	})
+
+	// Test that concurrent fetches share one request
+	t.Run("concurrent fetches", func(t *testing.T) {
+		freshToken := testJWT(time.Now().Add(time.Hour))
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte(`"` + freshToken + `"`))
+		}))
+		defer server.Close()
+
+		manager := NewAuthTokenManager(NewTVHttpClient(server.URL, "device", "session", "sign"))
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if token, err := manager.FetchToken(); err != nil || token != freshToken {
+					t.Errorf("FetchToken() = %v, %v", token, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := calls.Load(); got != 1 {
+			t.Errorf("token endpoint called %d times, want 1", got)
+		}
+	})
+
+	// Test that slow rotation listeners do not hold up token fetches
+	t.Run("listeners run after the fetch", func(t *testing.T) {
+		freshToken := testJWT(time.Now().Add(time.Hour))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"` + freshToken + `"`))
+		}))
+		defer server.Close()
+
+		manager := NewAuthTokenManager(NewTVHttpClient(server.URL, "device", "session", "sign"))
+		entered := make(chan struct{})
+		release := make(chan struct{})
+		var calls atomic.Int32
+		manager.OnTokenRotated(func(TokenRotation) {
+			if calls.Add(1) == 1 {
+				close(entered)
+				<-release
+			}
+		})
+		defer close(release)
+
+		go manager.RefreshToken()
+		<-entered
+
+		done := make(chan error, 1)
+		go func() { done <- manager.RefreshToken() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("RefreshToken() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("RefreshToken() blocked behind a rotation listener")
+		}
+	})
+
 	// Test concurrent access
 	t.Run("concurrent access", func(t *testing.T) {
-		manager := GetAuthTokenManager()
+		manager := NewAuthTokenManager(nil)
 		const goroutines = 100
 		var wg sync.WaitGroup
 		wg.Add(goroutines)
@@ -93,7 +321,7 @@ func TestAuthTokenManager(t *testing.T) {
 			},
 		}
 
-		manager := GetAuthTokenManager()
+		manager := NewAuthTokenManager(nil)
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
 				manager.SetToken(tt.token)
@@ -105,3 +333,29 @@ func TestAuthTokenManager(t *testing.T) {
 		}
 	})
 }
+
+// testJWT builds an unsigned JWT carrying only an exp claim
+func testJWT(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." + payload + ".signature"
+}
+
+// resetDefaultAuthTokenManager clears the package-level default manager for
+// the duration of a test
+func resetDefaultAuthTokenManager(t *testing.T) {
+	t.Helper()
+	initMu.Lock()
+	instanceMu.Lock()
+	savedInstance, savedInitialized := instance, initialized
+	instance, initialized = nil, false
+	instanceMu.Unlock()
+	initMu.Unlock()
+
+	t.Cleanup(func() {
+		initMu.Lock()
+		instanceMu.Lock()
+		instance, initialized = savedInstance, savedInitialized
+		instanceMu.Unlock()
+		initMu.Unlock()
+	})
+}
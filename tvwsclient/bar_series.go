@@ -0,0 +1,290 @@
+package tvwsclient
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBarSeriesLimit is the number of bars each BarSeries keeps unless
+// WithBarSeriesLimit says otherwise
+const defaultBarSeriesLimit = 5000
+
+// BarOpenedEvent is emitted when a chart series receives a bar newer than its
+// latest one, after the initial snapshot
+type BarOpenedEvent struct {
+	SessionID string
+	SeriesID  string
+	Bar       CandleData
+}
+
+// BarClosedEvent is emitted for the previous latest bar when a newer bar opens
+type BarClosedEvent struct {
+	SessionID string
+	SeriesID  string
+	Bar       CandleData
+}
+
+func (BarOpenedEvent) isEvent() {}
+func (BarClosedEvent) isEvent() {}
+
+// BarSeries is the current state of one chart series, merged from
+// timescale_update snapshots and du updates. Bars are matched by their open
+// time rather than their protocol index, which shifts when older bars are
+// loaded. Bars of an earlier series generation are dropped with the first
+// update of a modified series. It is safe for concurrent use.
+type BarSeries struct {
+	sessionID string
+	seriesID  string
+	limit     int // maximum number of bars kept, 0 for no limit
+
+	mu         sync.RWMutex
+	bars       []CandleData // sorted by timestamp
+	generation int          // ChartSeries.Generation the bars belong to
+}
+
+func newBarSeries(sessionID, seriesID string, limit int) *BarSeries {
+	return &BarSeries{
+		sessionID: sessionID,
+		seriesID:  seriesID,
+		limit:     limit,
+	}
+}
+
+// SessionID returns the chart session of the series
+func (b *BarSeries) SessionID() string {
+	return b.sessionID
+}
+
+// SeriesID returns the series ID, e.g. "sds_1"
+func (b *BarSeries) SeriesID() string {
+	return b.seriesID
+}
+
+// Len returns the number of bars
+func (b *BarSeries) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.bars)
+}
+
+// Last returns the latest bar, which may still be forming
+func (b *BarSeries) Last() (CandleData, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bars) == 0 {
+		return CandleData{}, false
+	}
+	return b.bars[len(b.bars)-1], true
+}
+
+// Range returns the bars opened between from and to (inclusive)
+func (b *BarSeries) Range(from, to time.Time) []CandleData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	start := sort.Search(len(b.bars), func(i int) bool { return b.bars[i].Timestamp >= from.Unix() })
+	end := sort.Search(len(b.bars), func(i int) bool { return b.bars[i].Timestamp > to.Unix() })
+	if start >= end {
+		return nil
+	}
+	return append([]CandleData(nil), b.bars[start:end]...)
+}
+
+// Bars returns all bars sorted by time
+func (b *BarSeries) Bars() []CandleData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]CandleData(nil), b.bars...)
+}
+
+// apply merges the bars of data, sent for generation of the series, and
+// returns the bar that closed and the one that opened when the latest bar
+// advanced, nil otherwise
+func (b *BarSeries) apply(meta candleMeta, generation int, data SeriesData, now time.Time) (closed, opened *CandleData) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A modified series starts over with the snapshot of its new generation
+	if generation != b.generation {
+		b.bars = nil
+		b.generation = generation
+	}
+
+	previous := int64(-1)
+	if n := len(b.bars); n > 0 {
+		previous = b.bars[n-1].Timestamp
+	}
+
+	touched := make([]int64, 0, len(data.S))
+	for _, bar := range data.S {
+		candle, ok := newCandle(meta, bar)
+		if !ok {
+			continue
+		}
+		b.upsert(candle)
+		touched = append(touched, candle.Timestamp)
+	}
+	if len(b.bars) == 0 {
+		return nil, nil
+	}
+
+	// Every bar but the latest is final; the latest once bar_close_time passed
+	latest := b.bars[len(b.bars)-1].Timestamp
+	for _, t := range touched {
+		if t == latest {
+			b.bars[len(b.bars)-1].Final = data.Lbs.BarCloseTime > 0 && now.Unix() >= data.Lbs.BarCloseTime
+		} else {
+			b.setFinal(t)
+		}
+	}
+	if previous >= 0 && previous != latest {
+		b.setFinal(previous)
+	}
+	b.trim()
+
+	if previous < 0 || latest <= previous {
+		return nil, nil
+	}
+	if i := b.find(previous); i >= 0 {
+		closedBar := b.bars[i]
+		closed = &closedBar
+	}
+	openedBar := b.bars[len(b.bars)-1]
+	if closed == nil {
+		return nil, nil
+	}
+	return closed, &openedBar
+}
+
+// find returns the position of the bar opened at t, or -1
+func (b *BarSeries) find(t int64) int {
+	i := sort.Search(len(b.bars), func(i int) bool { return b.bars[i].Timestamp >= t })
+	if i < len(b.bars) && b.bars[i].Timestamp == t {
+		return i
+	}
+	return -1
+}
+
+func (b *BarSeries) setFinal(t int64) {
+	if i := b.find(t); i >= 0 {
+		b.bars[i].Final = true
+	}
+}
+
+// upsert inserts candle or replaces the bar with the same timestamp
+func (b *BarSeries) upsert(candle CandleData) {
+	if i := b.find(candle.Timestamp); i >= 0 {
+		b.bars[i] = candle
+		return
+	}
+	i := sort.Search(len(b.bars), func(i int) bool { return b.bars[i].Timestamp >= candle.Timestamp })
+	b.bars = append(b.bars, CandleData{})
+	copy(b.bars[i+1:], b.bars[i:])
+	b.bars[i] = candle
+}
+
+// trim drops the oldest bars beyond the limit
+func (b *BarSeries) trim() {
+	if b.limit <= 0 || len(b.bars) <= b.limit {
+		return
+	}
+	b.bars = append(b.bars[:0], b.bars[len(b.bars)-b.limit:]...)
+}
+
+// barStore holds the bar series of all chart sessions of a client
+type barStore struct {
+	mu     sync.RWMutex
+	series map[barSeriesKey]*BarSeries
+	limit  int
+}
+
+type barSeriesKey struct {
+	session string
+	series  string
+}
+
+func newBarStore(limit int) *barStore {
+	return &barStore{series: make(map[barSeriesKey]*BarSeries), limit: limit}
+}
+
+func (s *barStore) get(session, seriesID string) (*BarSeries, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.series[barSeriesKey{session, seriesID}]
+	return b, ok
+}
+
+func (s *barStore) getOrCreate(session, seriesID string) *BarSeries {
+	key := barSeriesKey{session, seriesID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.series[key]
+	if !ok {
+		b = newBarSeries(session, seriesID, s.limit)
+		s.series[key] = b
+	}
+	return b
+}
+
+// remove drops one series, or every series of the session when seriesID is empty
+func (s *barStore) remove(session, seriesID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.series {
+		if key.session == session && (seriesID == "" || key.series == seriesID) {
+			delete(s.series, key)
+		}
+	}
+}
+
+// BarSeries returns the bar series of a chart session, available once its
+// first timescale_update or du message was read. Only series registered with
+// the client, through NewChartSession or SubscriptionChartSessionSymbol, are
+// kept.
+func (c *Client) BarSeries(session, seriesID string) (*BarSeries, bool) {
+	return c.bars.get(session, seriesID)
+}
+
+// applyBars merges a chart event into the bar series of its session and
+// returns the resulting bar closed/opened events. Series that are not
+// registered, such as those of deleted sessions whose updates were still in
+// flight, are skipped.
+func (c *Client) applyBars(event Event) []Event {
+	var (
+		session string
+		series  map[string]SeriesData
+	)
+	switch e := event.(type) {
+	case TimescaleUpdateEvent:
+		session, series = e.ChartSessionID, e.Data.Series
+	case BarUpdateEvent:
+		session, series = e.ChartSessionID, e.Data.Series
+	default:
+		return nil
+	}
+
+	now := time.Now()
+	var events []Event
+	for _, id := range sortedSeriesIDs(series) {
+		s, ok := c.LookupSeries(session, id)
+		if !ok {
+			continue
+		}
+		closed, opened := c.bars.getOrCreate(session, id).apply(newCandleMeta(s.Symbol, s.Interval), s.Generation, series[id], now)
+		if opened != nil {
+			events = append(events,
+				BarClosedEvent{SessionID: session, SeriesID: id, Bar: *closed},
+				BarOpenedEvent{SessionID: session, SeriesID: id, Bar: *opened})
+		}
+	}
+	return events
+}
+
+func sortedSeriesIDs(series map[string]SeriesData) []string {
+	ids := make([]string, 0, len(series))
+	for id := range series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
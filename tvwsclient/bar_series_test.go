@@ -0,0 +1,224 @@
+package tvwsclient
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func seriesData(barCloseTime int64, bars ...DuSeriesData) SeriesData {
+	data := SeriesData{S: bars}
+	data.Lbs.BarCloseTime = barCloseTime
+	return data
+}
+
+func TestBarSeriesApply(t *testing.T) {
+	meta := candleMeta{"BINANCE", "BTCUSDT", "1"}
+	now := time.Unix(150, 0)
+	b := newBarSeries("cs_1", "sds_1", 0)
+
+	closed, opened := b.apply(meta, 0, seriesData(180,
+		DuSeriesData{I: 0, V: []float64{0, 1, 1, 1, 1, 5}},
+		DuSeriesData{I: 1, V: []float64{60, 2, 2, 2, 2, 5}},
+		DuSeriesData{I: 2, V: []float64{120, 3, 3, 3, 3, 5}},
+	), now)
+	if closed != nil || opened != nil {
+		t.Errorf("initial snapshot emitted closed=%v opened=%v", closed, opened)
+	}
+	if b.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", b.Len())
+	}
+	if last, _ := b.Last(); last.Timestamp != 120 || last.Final || last.Symbol != "BTCUSDT" {
+		t.Errorf("Last() = %+v, want forming bar at 120", last)
+	}
+	if first := b.Bars()[0]; !first.Final {
+		t.Error("older bars should be final")
+	}
+
+	// An update of the forming bar changes it in place
+	closed, opened = b.apply(meta, 0, seriesData(180, DuSeriesData{I: 2, V: []float64{120, 3, 4, 3, 3.5, 6}}), now)
+	if closed != nil || opened != nil {
+		t.Errorf("update of the forming bar emitted closed=%v opened=%v", closed, opened)
+	}
+	if last, _ := b.Last(); last.Close != 3.5 || last.Volume != 6 || b.Len() != 3 {
+		t.Errorf("Last() = %+v after update, Len() = %d", last, b.Len())
+	}
+
+	// A newer bar closes the previous one
+	closed, opened = b.apply(meta, 0, seriesData(240, DuSeriesData{I: 3, V: []float64{180, 5, 5, 5, 5}}), time.Unix(190, 0))
+	if closed == nil || closed.Timestamp != 120 || !closed.Final || closed.Close != 3.5 {
+		t.Errorf("closed = %+v, want final bar at 120", closed)
+	}
+	if opened == nil || opened.Timestamp != 180 || opened.Final {
+		t.Errorf("opened = %+v, want forming bar at 180", opened)
+	}
+
+	got := b.Range(time.Unix(60, 0), time.Unix(120, 0))
+	if len(got) != 2 || got[0].Timestamp != 60 || got[1].Timestamp != 120 {
+		t.Errorf("Range(60, 120) = %+v", got)
+	}
+	if got := b.Range(time.Unix(500, 0), time.Unix(600, 0)); got != nil {
+		t.Errorf("Range() outside the series = %+v", got)
+	}
+
+	// Older bars loaded later are merged in order without events
+	closed, opened = b.apply(meta, 0, seriesData(240, DuSeriesData{I: 0, V: []float64{-60, 0, 0, 0, 0}}), time.Unix(190, 0))
+	if closed != nil || opened != nil || b.Bars()[0].Timestamp != -60 || b.Len() != 5 {
+		t.Errorf("older bar merge: closed=%v opened=%v bars=%+v", closed, opened, b.Bars())
+	}
+}
+
+func TestBarSeriesLimit(t *testing.T) {
+	b := newBarSeries("cs_1", "sds_1", 2)
+	for i := 0; i < 5; i++ {
+		b.apply(candleMeta{}, 0, seriesData(0, DuSeriesData{I: i, V: []float64{float64(i * 60), 1, 1, 1, 1}}), time.Now())
+	}
+	bars := b.Bars()
+	if len(bars) != 2 || bars[0].Timestamp != 180 || bars[1].Timestamp != 240 {
+		t.Errorf("Bars() = %+v, want the last 2 bars", bars)
+	}
+}
+
+func TestBarSeriesConcurrentReaders(t *testing.T) {
+	b := newBarSeries("cs_1", "sds_1", 0)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					b.Last()
+					b.Len()
+					b.Range(time.Unix(0, 0), time.Unix(1<<30, 0))
+				}
+			}
+		}()
+	}
+	for i := 0; i < 500; i++ {
+		b.apply(candleMeta{}, 0, seriesData(0, DuSeriesData{I: i, V: []float64{float64(i), 1, 1, 1, 1}}), time.Now())
+	}
+	close(stop)
+	wg.Wait()
+	if b.Len() != 500 {
+		t.Errorf("Len() = %d, want 500", b.Len())
+	}
+}
+
+func TestClientBarSeriesEvents(t *testing.T) {
+	start := time.Now().Truncate(time.Minute).Add(-4 * time.Minute)
+	bars := tvwstest.GenerateBars(start, time.Minute, 5)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent[TimescaleUpdateEvent](t, events)
+	series, ok := client.BarSeries("cs_test", "sds_1")
+	if !ok || series.Len() != 5 {
+		t.Fatalf("BarSeries() = %v, %v, want 5 bars", series, ok)
+	}
+
+	next := tvwstest.Bar{Time: bars[4].Time + 60, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 3}
+	srv.PushBar(testSymbol, next)
+
+	closed := waitForEvent[BarClosedEvent](t, events)
+	if closed.SessionID != "cs_test" || closed.SeriesID != "sds_1" || closed.Bar.Timestamp != bars[4].Time || !closed.Bar.Final {
+		t.Errorf("BarClosedEvent = %+v", closed)
+	}
+	opened := waitForEvent[BarOpenedEvent](t, events)
+	if opened.Bar.Timestamp != next.Time || opened.Bar.Close != 1.5 || opened.Bar.Symbol != "BTCUSDT" {
+		t.Errorf("BarOpenedEvent = %+v", opened)
+	}
+	if last, _ := series.Last(); last.Timestamp != next.Time || series.Len() != 6 {
+		t.Errorf("Last() = %+v, Len() = %d", last, series.Len())
+	}
+
+	if err := SendChartDeleteSessionMessage(client, "cs_test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.BarSeries("cs_test", "sds_1"); ok {
+		t.Error("bar series of a deleted session is still available")
+	}
+}
+
+func TestClientBarSeriesSkipsUnregisteredSessions(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, time.Minute, 5)))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	if client.bars.limit != defaultBarSeriesLimit {
+		t.Errorf("bar series limit = %d, want %d", client.bars.limit, defaultBarSeriesLimit)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	// A session created with the raw messages is not registered
+	if err := SendChartCreateSessionMessage(client, "cs_raw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendResolveSymbolMessage(client, "cs_raw", testSymbol); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendCreateSeriesMessage(client, "cs_raw", "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := waitForEvent[TimescaleUpdateEvent](t, events)
+	if _, ok := client.BarSeries("cs_raw", "sds_1"); ok {
+		t.Error("bar series of an unregistered session was stored")
+	}
+
+	// Updates still in flight when a session is deleted are dropped
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent[TimescaleUpdateEvent](t, events)
+	if err := SendChartDeleteSessionMessage(client, "cs_test"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.ChartSessionID = "cs_test"
+	client.applyBars(snapshot)
+	if _, ok := client.BarSeries("cs_test", "sds_1"); ok {
+		t.Error("bar series of a deleted session was re-created")
+	}
+}
+
+func TestClientBarSeriesWithoutSubscribers(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, time.Minute, 5)))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if series, ok := client.BarSeries("cs_test", "sds_1"); ok && series.Len() == 5 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bar series was not filled without event subscribers")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package tvwsclient
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Positions in the bar tuple [timestamp, open, high, low, close, volume]
+const (
+	barTimestamp = iota
+	barOpen
+	barHigh
+	barLow
+	barClose
+	barVolume
+)
+
+// candleMeta identifies the series candles belong to
+type candleMeta struct {
+	exchange  string
+	symbol    string
+	timeframe string
+}
+
+func newCandleMeta(symbol, interval string) candleMeta {
+	exchange, ticker := splitSymbol(symbol)
+	return candleMeta{exchange: exchange, symbol: ticker, timeframe: interval}
+}
+
+// Candles converts the bars of a series to candles sorted by time. Bars
+// without a volume column get a zero Volume. Every bar but the latest is
+// final; the latest is final once lbs.bar_close_time has passed.
+func (d SeriesData) Candles(exchange, symbol, timeframe string) []CandleData {
+	return seriesCandles(candleMeta{exchange, symbol, timeframe}, d, time.Now())
+}
+
+// CandlesFromTimescaleUpdate converts a timescale_update to candles keyed by
+// series ID, with exchange, symbol and timeframe taken from the series the
+// client registered for the session
+func (c *Client) CandlesFromTimescaleUpdate(msg *TimescaleUpdateMessage) map[string][]CandleData {
+	return c.sessionCandles(msg.ChartSessionID, msg.Data.Series)
+}
+
+// CandlesFromBarUpdate converts a du update to candles keyed by series ID,
+// like CandlesFromTimescaleUpdate
+func (c *Client) CandlesFromBarUpdate(msg *DuMessage) map[string][]CandleData {
+	return c.sessionCandles(msg.ChartSessionID, msg.Data.Series)
+}
+
+func (c *Client) sessionCandles(session string, series map[string]SeriesData) map[string][]CandleData {
+	now := time.Now()
+	candles := make(map[string][]CandleData, len(series))
+	for id, data := range series {
+		var meta candleMeta
+		if s, ok := c.LookupSeries(session, id); ok {
+			meta = newCandleMeta(s.Symbol, s.Interval)
+		}
+		candles[id] = seriesCandles(meta, data, now)
+	}
+	return candles
+}
+
+func seriesCandles(meta candleMeta, data SeriesData, now time.Time) []CandleData {
+	latest := int64(math.MinInt64)
+	for _, bar := range data.S {
+		if len(bar.V) > barClose && int64(bar.V[barTimestamp]) > latest {
+			latest = int64(bar.V[barTimestamp])
+		}
+	}
+	closed := data.Lbs.BarCloseTime > 0 && now.Unix() >= data.Lbs.BarCloseTime
+
+	candles := make([]CandleData, 0, len(data.S))
+	for _, bar := range data.S {
+		candle, ok := newCandle(meta, bar)
+		if !ok {
+			continue
+		}
+		candle.Final = candle.Timestamp < latest || closed
+		candles = append(candles, candle)
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Timestamp < candles[j].Timestamp
+	})
+	return candles
+}
+
+// newCandle converts a bar tuple, reporting false when it has no OHLC values
+func newCandle(meta candleMeta, bar DuSeriesData) (CandleData, bool) {
+	if len(bar.V) <= barClose {
+		return CandleData{}, false
+	}
+	candle := CandleData{
+		Exchange:  meta.exchange,
+		Symbol:    meta.symbol,
+		Timeframe: meta.timeframe,
+		Timestamp: int64(bar.V[barTimestamp]),
+		Open:      bar.V[barOpen],
+		High:      bar.V[barHigh],
+		Low:       bar.V[barLow],
+		Close:     bar.V[barClose],
+	}
+	if len(bar.V) > barVolume {
+		candle.Volume = bar.V[barVolume]
+	}
+	return candle, true
+}
@@ -0,0 +1,85 @@
+package tvwsclient
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestSeriesCandles(t *testing.T) {
+	data := SeriesData{S: []DuSeriesData{
+		{I: 1, V: []float64{120, 2, 3, 1, 2.5}}, // no volume column
+		{I: 0, V: []float64{60, 1, 2, 0.5, 1.5, 10}},
+		{I: 2, V: []float64{180}}, // malformed
+	}}
+	data.Lbs.BarCloseTime = 180
+	meta := candleMeta{"BINANCE", "BTCUSDT", "1"}
+
+	want := []CandleData{
+		{Exchange: "BINANCE", Symbol: "BTCUSDT", Timeframe: "1", Timestamp: 60, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10, Final: true},
+		{Exchange: "BINANCE", Symbol: "BTCUSDT", Timeframe: "1", Timestamp: 120, Open: 2, High: 3, Low: 1, Close: 2.5, Final: false},
+	}
+	if got := seriesCandles(meta, data, time.Unix(150, 0)); !reflect.DeepEqual(got, want) {
+		t.Errorf("seriesCandles() before bar close = %+v, want %+v", got, want)
+	}
+
+	want[1].Final = true
+	if got := seriesCandles(meta, data, time.Unix(180, 0)); !reflect.DeepEqual(got, want) {
+		t.Errorf("seriesCandles() after bar close = %+v, want %+v", got, want)
+	}
+
+	data.Lbs.BarCloseTime = 0
+	if got := seriesCandles(meta, data, time.Unix(1000, 0)); got[1].Final {
+		t.Error("latest bar is final without bar_close_time")
+	}
+}
+
+func TestClientCandlesUseRegistry(t *testing.T) {
+	start := time.Now().Truncate(time.Minute).Add(-4 * time.Minute) // last bar is forming
+	bars := tvwstest.GenerateBars(start, time.Minute, 5)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := waitForEvent[TimescaleUpdateEvent](t, events)
+	candles := client.CandlesFromTimescaleUpdate(snapshot.TimescaleUpdateMessage)["sds_1"]
+	if len(candles) != 5 {
+		t.Fatalf("got %d candles, want 5", len(candles))
+	}
+	for i, candle := range candles {
+		if candle.Exchange != "BINANCE" || candle.Symbol != "BTCUSDT" || candle.Timeframe != "1" {
+			t.Fatalf("candle %d metadata = %s/%s/%s", i, candle.Exchange, candle.Symbol, candle.Timeframe)
+		}
+		if candle.Timestamp != bars[i].Time || candle.Close != bars[i].Close || candle.Volume != bars[i].Volume {
+			t.Errorf("candle %d = %+v, want %+v", i, candle, bars[i])
+		}
+		if final := i < 4; candle.Final != final {
+			t.Errorf("candle %d Final = %v, want %v", i, candle.Final, final)
+		}
+	}
+
+	forming := tvwstest.Bar{Time: start.Add(10 * time.Minute).Unix(), Open: 1, High: 1, Low: 1, Close: 1}
+	srv.PushBar(testSymbol, forming)
+	update := waitForEvent[BarUpdateEvent](t, events)
+	candles = client.CandlesFromBarUpdate(update.DuMessage)["sds_1"]
+	if len(candles) != 1 || candles[0].Timestamp != forming.Time || candles[0].Final || candles[0].Symbol != "BTCUSDT" {
+		t.Errorf("du candles = %+v, want the forming bar", candles)
+	}
+
+	// Sessions the client does not know still convert, without metadata
+	unknown := &DuMessage{ChartSessionID: "cs_other", Data: update.Data}
+	if got := client.CandlesFromBarUpdate(unknown)["sds_1"]; len(got) != 1 || got[0].Symbol != "" {
+		t.Errorf("unregistered session candles = %+v", got)
+	}
+}
@@ -0,0 +1,334 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SeriesRange selects the bars of a series: either the last Bars bars or
+// the bars between From and To
+type SeriesRange struct {
+	Bars     int64
+	From, To time.Time
+}
+
+// BarCount selects the last n bars
+func BarCount(n int64) SeriesRange {
+	return SeriesRange{Bars: n}
+}
+
+// TimeRange selects the bars between from and to
+func TimeRange(from, to time.Time) SeriesRange {
+	return SeriesRange{From: from, To: to}
+}
+
+// IsTimeRange reports whether the range is bounded by time instead of a bar count
+func (r SeriesRange) IsTimeRange() bool {
+	return !r.From.IsZero() || !r.To.IsZero()
+}
+
+// String returns the bar count or the r,from:to form used by the protocol
+func (r SeriesRange) String() string {
+	if r.IsTimeRange() {
+		return fmt.Sprintf("r,%d:%d", r.From.Unix(), r.To.Unix())
+	}
+	return strconv.FormatInt(r.Bars, 10)
+}
+
+func (r SeriesRange) validate(op string) error {
+	if r.IsTimeRange() {
+		if r.From.IsZero() || r.To.IsZero() || !r.From.Before(r.To) {
+			return WrapValidationError(op, fmt.Sprintf("invalid time range %v - %v", r.From, r.To), nil)
+		}
+		return nil
+	}
+	if r.Bars < 1 {
+		return WrapValidationError(op, fmt.Sprintf("bar count must be at least 1, got %d", r.Bars), nil)
+	}
+	return nil
+}
+
+// param returns the range as the last parameter of modify_series
+func (r SeriesRange) param() interface{} {
+	if r.IsTimeRange() {
+		return r.String()
+	}
+	return r.Bars
+}
+
+// ChartSeries is a series hosted by a chart session. Its IDs are allocated by
+// the session: the Nth series uses sds_N, sds_sym_N and sN. Every
+// modification starts a new generation with its own turnaround (sN_2, ...),
+// so updates still in flight for the previous generation can be told apart.
+type ChartSeries struct {
+	ID         string // series ID, e.g. "sds_2"
+	SymbolID   string // resolved symbol ID, e.g. "sds_sym_2"
+	Turnaround string // series set, e.g. "s2"
+	Symbol     string // symbol or SymbolDescriptor.String()
+	Interval   string
+	BarCount   int64
+	Range      SeriesRange // time range, zero when the series is bounded by BarCount
+	Generation int
+}
+
+func newChartSeries(n int, symbol, interval string, barCount int64) ChartSeries {
+	suffix := strconv.Itoa(n)
+	return ChartSeries{
+		ID:         "sds_" + suffix,
+		SymbolID:   "sds_sym_" + suffix,
+		Turnaround: "s" + suffix,
+		Symbol:     symbol,
+		Interval:   interval,
+		BarCount:   barCount,
+		Generation: 1,
+	}
+}
+
+// next returns the series with a new generation and turnaround
+func (s ChartSeries) next() ChartSeries {
+	s.Generation++
+	s.Turnaround = "s" + strings.TrimPrefix(s.ID, "sds_") + "_" + strconv.Itoa(s.Generation)
+	return s
+}
+
+// ChartSession is a chart session hosting any number of series. It is
+// registered with the client and re-created with all series after a reconnect.
+type ChartSession struct {
+	client *Client
+	id     string
+
+	mu     sync.Mutex
+	next   int // number of the last allocated series
+	series []ChartSeries
+	closed bool
+}
+
+// NewChartSession creates a chart session with a generated ID using the
+// client timezone
+func NewChartSession(client *Client) (*ChartSession, error) {
+	id := GenerateSession("cs_")
+	if err := SendChartCreateSessionMessage(client, id); err != nil {
+		return nil, err
+	}
+	if err := SendSwitchTimezoneMessage(client, id); err != nil {
+		return nil, err
+	}
+	client.subscriptions.addChartSession(id, client.SessionTimezone(id))
+	return &ChartSession{client: client, id: id}, nil
+}
+
+// ID returns the chart session ID
+func (s *ChartSession) ID() string {
+	return s.id
+}
+
+// AddSeries resolves symbol and streams barCount bars of interval as a new
+// series. symbol may be a plain symbol or a SymbolDescriptor.String().
+func (s *ChartSession) AddSeries(symbol string, interval Interval, barCount int64) (ChartSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ChartSeries{}, WrapSessionError("add_series", ErrSessionNotFound)
+	}
+	if err := interval.Validate(nil); err != nil {
+		return ChartSeries{}, err
+	}
+	if err := BarCount(barCount).validate("add_series"); err != nil {
+		return ChartSeries{}, err
+	}
+
+	series := newChartSeries(s.next+1, symbol, interval.String(), barCount)
+	if err := sendResolveSymbol(s.client, s.id, series.SymbolID, series.Symbol); err != nil {
+		return ChartSeries{}, err
+	}
+	if err := sendCreateSeries(s.client, s.id, series); err != nil {
+		return ChartSeries{}, err
+	}
+	s.next++
+	s.series = append(s.series, series)
+	s.client.subscriptions.addSeries(s.id, series)
+	return series, nil
+}
+
+// RemoveSeries stops streaming a series
+func (s *ChartSession) RemoveSeries(seriesID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexOf(seriesID)
+	if i < 0 {
+		return WrapSessionError("remove_series", fmt.Errorf("%w: series %s", ErrSessionNotFound, seriesID))
+	}
+	if err := SendRemoveSeriesMessage(s.client, s.id, seriesID); err != nil {
+		return err
+	}
+	s.series = append(s.series[:i], s.series[i+1:]...)
+	s.client.subscriptions.removeSeries(s.id, seriesID)
+	s.client.bars.remove(s.id, seriesID)
+	return nil
+}
+
+// ModifySeries switches a series to interval and rng without re-creating it.
+// Updates of the previous generation that arrive afterwards are discarded.
+func (s *ChartSession) ModifySeries(seriesID string, interval Interval, rng SeriesRange) (ChartSeries, error) {
+	if err := interval.Validate(nil); err != nil {
+		return ChartSeries{}, err
+	}
+	if err := rng.validate("modify_series"); err != nil {
+		return ChartSeries{}, err
+	}
+	return s.modify(seriesID, func(series *ChartSeries) error {
+		series.Interval = interval.String()
+		series.setRange(rng)
+		return nil
+	})
+}
+
+// SetSymbol re-resolves a series to symbol, keeping its interval and range
+func (s *ChartSession) SetSymbol(seriesID string, symbol string) (ChartSeries, error) {
+	return s.modify(seriesID, func(series *ChartSeries) error {
+		series.Symbol = symbol
+		series.SymbolID = "sds_sym_" + strings.TrimPrefix(series.ID, "sds_") + "_" + strconv.Itoa(series.Generation)
+		return sendResolveSymbol(s.client, s.id, series.SymbolID, symbol)
+	})
+}
+
+// modify applies change to a new generation of a series and sends modify_series
+func (s *ChartSession) modify(seriesID string, change func(*ChartSeries) error) (ChartSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexOf(seriesID)
+	if i < 0 {
+		return ChartSeries{}, WrapSessionError("modify_series", fmt.Errorf("%w: series %s", ErrSessionNotFound, seriesID))
+	}
+
+	series := s.series[i].next()
+	if err := change(&series); err != nil {
+		return ChartSeries{}, err
+	}
+	// Register the new generation first so its updates are not taken for stale
+	// ones; its first update replaces the bars of the previous generation
+	s.client.subscriptions.addSeries(s.id, series)
+	if err := SendModifySeriesMessage(s.client, s.id, series); err != nil {
+		s.client.subscriptions.addSeries(s.id, s.series[i])
+		return ChartSeries{}, err
+	}
+	s.series[i] = series
+	return series, nil
+}
+
+// setRange stores rng, keeping the last bar count for time ranges
+func (s *ChartSeries) setRange(rng SeriesRange) {
+	if rng.IsTimeRange() {
+		s.Range = rng
+		return
+	}
+	s.Range = SeriesRange{}
+	s.BarCount = rng.Bars
+}
+
+// rangeParam returns the range parameter of create_series
+func (s ChartSeries) rangeParam() string {
+	if s.Range.IsTimeRange() {
+		return s.Range.String()
+	}
+	return ""
+}
+
+// Series returns the series of the session in creation order
+func (s *ChartSession) Series() []ChartSeries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ChartSeries(nil), s.series...)
+}
+
+// SeriesByID returns the series a sds_N key of a timescale_update or du
+// message refers to
+func (s *ChartSession) SeriesByID(seriesID string) (ChartSeries, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.indexOf(seriesID); i >= 0 {
+		return s.series[i], true
+	}
+	return ChartSeries{}, false
+}
+
+// SetTimezone switches the session to timezone, see SetChartSessionTimezone
+func (s *ChartSession) SetTimezone(timezone string) error {
+	return SetChartSessionTimezone(s.client, s.id, timezone)
+}
+
+// Close deletes the chart session and all of its series
+func (s *ChartSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if err := SendChartDeleteSessionMessage(s.client, s.id); err != nil {
+		return err
+	}
+	s.closed = true
+	s.series = nil
+	return nil
+}
+
+func (s *ChartSession) indexOf(seriesID string) int {
+	for i, series := range s.series {
+		if series.ID == seriesID {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropStaleSeries removes data of outdated series generations from chart
+// events. It reports false when nothing current is left to deliver.
+func (c *Client) dropStaleSeries(event Event) (Event, bool) {
+	switch e := event.(type) {
+	case TimescaleUpdateEvent:
+		return event, c.keepCurrentSeries(e.ChartSessionID, e.Data.Series, func() { e.Data.SDS1 = TimescaleUpdateData{}.SDS1 })
+	case BarUpdateEvent:
+		return event, c.keepCurrentSeries(e.ChartSessionID, e.Data.Series, func() { e.Data.SDS1 = DuData{}.SDS1 })
+	case SeriesLoadingEvent:
+		return event, !c.isStaleSeries(e.ChartSessionID, e.SeriesID, e.SeriesSet)
+	case SeriesCompletedEvent:
+		return event, !c.isStaleSeries(e.ChartSessionID, e.SeriesID, e.SeriesSet)
+	}
+	return event, true
+}
+
+// keepCurrentSeries deletes stale entries from series, calling clearSDS1 when
+// sds_1 is one of them, and reports whether any entry is left
+func (c *Client) keepCurrentSeries(session string, series map[string]SeriesData, clearSDS1 func()) bool {
+	if len(series) == 0 {
+		return true
+	}
+	for id, data := range series {
+		if c.isStaleSeries(session, id, data.T) {
+			delete(series, id)
+			if id == "sds_1" {
+				clearSDS1()
+			}
+		}
+	}
+	return len(series) > 0
+}
+
+// isStaleSeries reports whether turnaround belongs to an older generation of
+// a registered series
+func (c *Client) isStaleSeries(session, seriesID, turnaround string) bool {
+	if turnaround == "" {
+		return false
+	}
+	series, ok := c.subscriptions.lookupSeries(session, seriesID)
+	return ok && series.Turnaround != turnaround
+}
+
+// LookupSeries returns the series a chart session registered with the client
+// uses for seriesID
+func (c *Client) LookupSeries(session, seriesID string) (ChartSeries, bool) {
+	return c.subscriptions.lookupSeries(session, seriesID)
+}
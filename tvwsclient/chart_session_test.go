@@ -0,0 +1,301 @@
+package tvwsclient
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestTimescaleUpdateDecodesAllSeries(t *testing.T) {
+	payload := `{"sds_1":{"node":"n","s":[{"i":0,"v":[1,2,3,1,2,10]}],"t":"s1","lbs":{"bar_close_time":60}},` +
+		`"sds_12":{"s":[{"i":0,"v":[5,6,7,5,6,20]},{"i":1,"v":[65,6,7,5,6,20]}],"t":"s12"},` +
+		`"sds_sym_1":{},"st1":{},"index":1,"changes":[1]}`
+
+	var data TimescaleUpdateData
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(data.Series) != 2 {
+		t.Fatalf("Series has %d entries, want sds_1 and sds_12", len(data.Series))
+	}
+	if got := data.Series["sds_12"]; got.T != "s12" || len(got.S) != 2 || got.S[1].V[0] != 65 {
+		t.Errorf("sds_12 = %+v", got)
+	}
+	if got := data.Series["sds_1"]; got.Lbs.BarCloseTime != 60 || len(data.SDS1.S) != 1 {
+		t.Errorf("sds_1 = %+v, SDS1 = %+v", got, data.SDS1)
+	}
+	if data.Index != 1 {
+		t.Errorf("Index = %d, want 1", data.Index)
+	}
+
+	var du DuData
+	if err := json.Unmarshal([]byte(`{"sds_3":{"s":[{"i":4,"v":[1,1,1,1,1]}],"t":"s3"}}`), &du); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got := du.Series["sds_3"]; len(got.S) != 1 || got.S[0].I != 4 {
+		t.Errorf("du sds_3 = %+v", got)
+	}
+}
+
+func TestChartSessionMultipleSeries(t *testing.T) {
+	const otherSymbol = "NASDAQ:AAPL"
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(
+		tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, time.Minute, 10)),
+		tvwstest.WithBars(otherSymbol, tvwstest.GenerateBars(start, time.Hour, 10)),
+	)
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	chart, err := NewChartSession(client)
+	if err != nil {
+		t.Fatalf("NewChartSession() error = %v", err)
+	}
+	btc, err := chart.AddSeries(testSymbol, Minutes(1), 4)
+	if err != nil {
+		t.Fatalf("AddSeries() error = %v", err)
+	}
+	aapl, err := chart.AddSeries(otherSymbol, Hours(1), 6)
+	if err != nil {
+		t.Fatalf("AddSeries() error = %v", err)
+	}
+	if btc.ID != "sds_1" || btc.SymbolID != "sds_sym_1" || btc.Turnaround != "s1" {
+		t.Errorf("first series = %+v, want sds_1/sds_sym_1/s1", btc)
+	}
+	if aapl.ID != "sds_2" || aapl.SymbolID != "sds_sym_2" || aapl.Turnaround != "s2" {
+		t.Errorf("second series = %+v, want sds_2/sds_sym_2/s2", aapl)
+	}
+
+	counts := make(map[string]int)
+	for len(counts) < 2 {
+		update := waitForEvent[TimescaleUpdateEvent](t, events)
+		for id, data := range update.Data.Series {
+			series, ok := chart.SeriesByID(id)
+			if !ok {
+				t.Fatalf("timescale_update for unknown series %s", id)
+			}
+			counts[series.Symbol] = len(data.S)
+		}
+	}
+	if counts[testSymbol] != 4 || counts[otherSymbol] != 6 {
+		t.Errorf("snapshot bar counts = %v, want 4 and 6", counts)
+	}
+
+	srv.PushBar(otherSymbol, tvwstest.Bar{Time: start.Add(10 * time.Hour).Unix(), Close: 7})
+	update := waitForEvent[BarUpdateEvent](t, events)
+	if _, ok := update.Data.Series["sds_2"]; !ok || len(update.Data.Series) != 1 {
+		t.Errorf("du series = %v, want only sds_2", update.Data.Series)
+	}
+	if series, ok := client.LookupSeries(chart.ID(), "sds_2"); !ok || series.Symbol != otherSymbol {
+		t.Errorf("LookupSeries(sds_2) = %+v, %v", series, ok)
+	}
+
+	// Both series are re-created on the same IDs after a reconnect
+	srv.DropConnections()
+	waitForEvent[SubscriptionsRestoredEvent](t, events)
+	created, err := srv.WaitForMessages(ctx, "create_series", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created[2].ParamString(1) != "sds_1" || created[3].ParamString(1) != "sds_2" {
+		t.Errorf("restored series = %s, %s, want sds_1, sds_2", created[2].ParamString(1), created[3].ParamString(1))
+	}
+
+	if err := chart.RemoveSeries("sds_1"); err != nil {
+		t.Fatalf("RemoveSeries() error = %v", err)
+	}
+	if err := chart.RemoveSeries("sds_1"); err == nil {
+		t.Error("RemoveSeries() of a removed series succeeded")
+	}
+	if got := chart.Series(); len(got) != 1 || got[0].ID != "sds_2" {
+		t.Errorf("Series() = %+v, want only sds_2", got)
+	}
+	third, err := chart.AddSeries(testSymbol, Minutes(5), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.ID != "sds_3" {
+		t.Errorf("series IDs are reused: got %s, want sds_3", third.ID)
+	}
+
+	subs := client.Subscriptions()
+	if len(subs) != 1 || len(subs[0].Series) != 2 || subs[0].Symbol != otherSymbol {
+		t.Errorf("Subscriptions() = %+v, want sds_2 and sds_3", subs)
+	}
+
+	if err := chart.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Subscriptions()) != 0 {
+		t.Error("closed chart session is still registered")
+	}
+	if _, err := chart.AddSeries(testSymbol, Minutes(1), 1); err == nil {
+		t.Error("AddSeries() on a closed session succeeded")
+	}
+}
+
+func TestChartSessionModifySeries(t *testing.T) {
+	const otherSymbol = "NASDAQ:AAPL"
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := tvwstest.GenerateBars(start, time.Minute, 100)
+	srv := tvwstest.NewServer(
+		tvwstest.WithBars(testSymbol, bars),
+		tvwstest.WithBars(otherSymbol, tvwstest.GenerateBars(start, time.Minute, 100)),
+	)
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	chart, err := NewChartSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := chart.AddSeries(testSymbol, Minutes(1), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent[SeriesCompletedEvent](t, events)
+
+	modified, err := chart.ModifySeries(series.ID, Minutes(5), BarCount(20))
+	if err != nil {
+		t.Fatalf("ModifySeries() error = %v", err)
+	}
+	if modified.Generation != 2 || modified.Turnaround != "s1_2" || modified.Interval != "5" || modified.BarCount != 20 {
+		t.Errorf("modified series = %+v", modified)
+	}
+	msgs, err := srv.WaitForMessages(ctx, "modify_series", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := msgs[0].Params; p[1] != "sds_1" || p[2] != "s1_2" || p[3] != "sds_sym_1" || p[4] != "5" || p[5] != float64(20) {
+		t.Errorf("modify_series params = %v", p)
+	}
+
+	snapshot := waitForEvent[TimescaleUpdateEvent](t, events)
+	if got := snapshot.Data.Series["sds_1"]; got.T != "s1_2" || len(got.S) != 20 {
+		t.Errorf("snapshot turnaround %s with %d bars, want s1_2 with 20", got.T, len(got.S))
+	}
+	waitForEvent[SeriesCompletedEvent](t, events)
+
+	// An update of the first generation still in flight is dropped
+	du := func(turnaround string, close float64) string {
+		return `{"m":"du","p":["` + chart.ID() + `",{"sds_1":{"s":[{"i":19,"v":[1,1,1,1,` +
+			strconv.FormatFloat(close, 'f', -1, 64) + `]}],"t":"` + turnaround + `"}}]}`
+	}
+	srv.Send(du("s1", 1), du("s1_2", 2))
+	update := waitForEvent[BarUpdateEvent](t, events)
+	if got := update.Data.SDS1; got.T != "s1_2" || got.S[0].V[4] != 2 {
+		t.Errorf("first delivered du = %+v, want the s1_2 update", got)
+	}
+
+	from, to := time.Unix(bars[10].Time, 0), time.Unix(bars[19].Time, 0)
+	ranged, err := chart.ModifySeries(series.ID, Minutes(1), TimeRange(from, to))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err = srv.WaitForMessages(ctx, "modify_series", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msgs[1].ParamString(5), ranged.Range.String(); got != want || want != "r,"+strconv.FormatInt(bars[10].Time, 10)+":"+strconv.FormatInt(bars[19].Time, 10) {
+		t.Errorf("modify_series range = %s, want %s", got, want)
+	}
+	snapshot = waitForEvent[TimescaleUpdateEvent](t, events)
+	if got := snapshot.Data.Series["sds_1"]; got.T != "s1_3" || len(got.S) != 10 {
+		t.Errorf("range snapshot turnaround %s with %d bars, want s1_3 with 10", got.T, len(got.S))
+	}
+
+	switched, err := chart.SetSymbol(series.ID, otherSymbol)
+	if err != nil {
+		t.Fatalf("SetSymbol() error = %v", err)
+	}
+	if switched.Symbol != otherSymbol || switched.SymbolID != "sds_sym_1_4" || switched.Range != ranged.Range {
+		t.Errorf("switched series = %+v", switched)
+	}
+	resolved := waitForEvent[SymbolResolvedEvent](t, events)
+	if resolved.SeriesID != "sds_sym_1_4" || resolved.SymbolInfo.ProName != otherSymbol {
+		t.Errorf("resolved %s as %s", resolved.SeriesID, resolved.SymbolInfo.ProName)
+	}
+	if got, _ := client.LookupSeries(chart.ID(), series.ID); got != switched {
+		t.Errorf("LookupSeries() = %+v, want %+v", got, switched)
+	}
+
+	if _, err := chart.ModifySeries(series.ID, Minutes(1), BarCount(0)); err == nil {
+		t.Error("ModifySeries() accepted a zero bar count")
+	}
+	if _, err := chart.ModifySeries(series.ID, Minutes(1), TimeRange(to, from)); err == nil {
+		t.Error("ModifySeries() accepted an inverted time range")
+	}
+	if _, err := chart.ModifySeries("sds_9", Minutes(1), BarCount(5)); err == nil {
+		t.Error("ModifySeries() of an unknown series succeeded")
+	}
+	if _, err := chart.ModifySeries(series.ID, Interval{}, BarCount(5)); err == nil {
+		t.Error("ModifySeries() accepted an invalid interval")
+	}
+}
+
+func TestChartSessionModifySeriesBars(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, time.Minute, 100)))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	chart, err := NewChartSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chart.AddSeries(testSymbol, Minutes(1), 0); err == nil {
+		t.Error("AddSeries() accepted a zero bar count")
+	}
+	if _, err := chart.AddSeries(testSymbol, Hours(0), 10); err == nil {
+		t.Error("AddSeries() accepted an invalid interval")
+	}
+	series, err := chart.AddSeries(testSymbol, Minutes(1), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent[SeriesCompletedEvent](t, events)
+	bars, ok := client.BarSeries(chart.ID(), series.ID)
+	if !ok || bars.Len() != 10 {
+		t.Fatalf("BarSeries() = %v, %v, want 10 bars", bars, ok)
+	}
+
+	// The snapshot of the new generation replaces the bars
+	if _, err := chart.ModifySeries(series.ID, Minutes(1), BarCount(20)); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent[SeriesCompletedEvent](t, events)
+	if bars.Len() != 20 {
+		t.Errorf("Len() after ModifySeries = %d, want 20", bars.Len())
+	}
+
+	// A modification that could not be sent leaves the bars alone
+	client.Close()
+	if _, err := chart.ModifySeries(series.ID, Minutes(1), BarCount(5)); err == nil {
+		t.Fatal("ModifySeries() on a closed client succeeded")
+	}
+	if bars.Len() != 20 {
+		t.Errorf("Len() after a failed ModifySeries = %d, want 20", bars.Len())
+	}
+	if current, _ := chart.SeriesByID(series.ID); current.Generation != 2 {
+		t.Errorf("Generation after a failed ModifySeries = %d, want 2", current.Generation)
+	}
+}
@@ -1,104 +1,209 @@
 package tvwsclient
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
-	"regexp"
-	"strings"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Add a new ConnectionState type for better state management
-type ConnectionState int
-
-const (
-	StateDisconnected ConnectionState = iota
-	StateConnecting
-	StateConnected
-)
-
 // Client represents a TradingView WebSocket client
 type Client struct {
-	ws            *websocket.Conn
-	mu            sync.Mutex // protects ws
-	requestHeader http.Header
-	wsURL         string
-	maxRetries    int
-	done          chan struct{} // Channel to signal connection close
-	pingInterval  time.Duration // Interval for sending ping messages
-	writeTimeout  time.Duration // Timeout for write operations
-	readTimeout   time.Duration // Timeout for read operations
-	state         ConnectionState
-	cancel        context.CancelFunc
-	
+	ws                  *websocket.Conn
+	mu                  sync.Mutex // protects ws
+	requestHeader       http.Header
+	wsURL               string
+	maxRetries          int
+	done                chan struct{}     // Channel to signal connection close
+	pingInterval        time.Duration     // Interval for sending ping messages
+	writeTimeout        time.Duration     // Timeout for write operations
+	readTimeout         time.Duration     // Timeout for read operations
+	handshakeTimeout    time.Duration     // Timeout for the WebSocket and session info handshake
+	handshakeTimeoutSet bool              // WithHandshakeTimeout was given, overriding the dialer's timeout
+	heartbeatTimeout    time.Duration     // Maximum gap between server heartbeats, 0 disables the watchdog
+	lastHeartbeat       atomic.Int64      // unix nanoseconds of the last server heartbeat or connect
+	dialer              *websocket.Dialer // base dialer, nil for the gorilla defaults
+	proxyURL            *url.URL
+	tlsConfig           *tls.Config
+	logger              *slog.Logger
+	timezone            string // default timezone of chart sessions
+	localeLanguage      string
+	localeCountry       string
+	optionErrors        []error // invalid option arguments, reported by NewClient
+
+	// Outbound flow control
+	writes         *writeQueue
+	writeQueueSize int
+	rateLimit      float64 // messages per second, 0 disables rate limiting
+	rateBurst      int
+	state          ConnectionState
+	stateListeners *stateNotifier
+	ctx            context.Context // cancelled when the client shuts down
+	cancel         context.CancelFunc
+
 	// Reconnection state
-	reconnecting  bool
-	retryCount    int
+	reconnectPolicy ReconnectPolicy
+	reconnecting    bool
+	reconnectDone   chan struct{} // closed when the reconnect in progress finishes
+	reconnectErr    error         // result of the last reconnect
+	retryCount      int
 	lastConnectTime time.Time
-	
+
 	// Callback for handling reconnection events
-	onReconnect   func() error
-}
+	onReconnect func() error
+
+	// Active chart and quote sessions replayed after reconnect
+	subscriptions *subscriptionRegistry
+	onRestore     func(*RestoreReport)
+
+	// Timezones selected for individual chart sessions
+	sessionTimezones *sessionTimezones
+
+	// Live bars of chart series
+	bars           *barStore
+	barSeriesLimit int // bars kept per series, 0 for no limit
 
-var heartbeatRegex = regexp.MustCompile(`~h~\d+`)
+	// Subscribers of typed events
+	events *eventHub
+
+	// Sessions whose events go to an internal owner, e.g. FetchHistory
+	privateSessions *privateSessions
+
+	// Session info packet received during the last handshake
+	serverInfo *ServerInfo
+
+	// Auth token source, falls back to the package-level AuthTokenManager when nil
+	tokenProvider AuthTokenManagerInterface
+
+	// In-band auth token rotation
+	onTokenRotated     func(TokenRotation, error)
+	tokenRotations     atomic.Uint64
+	stopTokenRotations func()
+	tokenRefreshLead   time.Duration // how long before expiry the provider's token is renewed, 0 disables
+
+	// Lifecycle
+	closeOnce sync.Once
+	closed    bool           // set once the client shuts down
+	loops     sync.WaitGroup // ping handler and read loops
+	err       error          // terminal error returned by Wait
+}
 
 // NewClient creates a new TradingView WebSocket client
 func NewClient(options ...Option) (*Client, error) {
+	return NewClientContext(context.Background(), options...)
+}
+
+// NewClientContext creates a new TradingView WebSocket client bound to ctx.
+// Cancelling ctx closes the client, and Wait then returns the context error.
+func NewClientContext(ctx context.Context, options ...Option) (*Client, error) {
 	client := &Client{
-		requestHeader: defaultHeaders(),
-		wsURL:         "wss://prodata.tradingview.com/socket.io/websocket?from=screener%2F",
-		maxRetries:    5,
-		done:          make(chan struct{}),
-		pingInterval:  30 * time.Second,
-		writeTimeout:  60 * time.Second,
-		readTimeout:   60 * time.Second,
-		state:         StateDisconnected, // Initial state
+		requestHeader:    defaultHeaders(),
+		wsURL:            defaultEndpoint.URL(),
+		maxRetries:       5,
+		done:             make(chan struct{}),
+		pingInterval:     30 * time.Second,
+		writeTimeout:     60 * time.Second,
+		readTimeout:      60 * time.Second,
+		handshakeTimeout: 10 * time.Second,
+		heartbeatTimeout: 30 * time.Second,
+		state:            StateDisconnected, // Initial state
+		stateListeners:   newStateNotifier(),
+		subscriptions:    newSubscriptionRegistry(),
+		sessionTimezones: newSessionTimezones(),
+		events:           newEventHub(),
+		privateSessions:  newPrivateSessions(),
+		logger:           slog.Default(),
+		timezone:         defaultTimezone,
+		localeLanguage:   defaultLocaleLanguage,
+		localeCountry:    defaultLocaleCountry,
+		writeQueueSize:   defaultWriteQueueSize,
+		rateLimit:        defaultRateLimit,
+		rateBurst:        defaultRateBurst,
+		tokenRefreshLead: defaultTokenRefreshLead,
+		barSeriesLimit:   defaultBarSeriesLimit,
 	}
 
 	// Apply options
 	for _, opt := range options {
 		opt(client)
 	}
+	if err := client.validate(); err != nil {
+		return nil, err
+	}
+
+	client.bars = newBarStore(client.barSeriesLimit)
+
+	if client.reconnectPolicy == nil {
+		client.reconnectPolicy = NewExponentialBackoff(time.Second, 30*time.Second, client.maxRetries)
+	}
+
+	client.ctx, client.cancel = context.WithCancel(ctx)
+
+	// All data frames go through the writer goroutine
+	client.writes = newWriteQueue(client.writeQueueSize)
+	go client.writeLoop()
+	client.watchTokenRotations()
 
 	if err := client.connect(); err != nil {
+		client.shutdown(err)
 		return nil, err
 	}
 
 	// Start ping handler
+	client.loops.Add(1)
 	go client.pingHandler()
 
+	if client.heartbeatTimeout > 0 {
+		client.loops.Add(1)
+		go client.heartbeatWatchdog()
+	}
+
+	// Shut down when the parent context is cancelled
+	context.AfterFunc(client.ctx, func() {
+		client.shutdown(ctx.Err())
+	})
+
 	return client, nil
 }
 
 // connect establishes a WebSocket connection
 func (c *Client) connect() error {
 	c.mu.Lock()
-	c.state = StateConnecting // Set state to connecting
+	if c.closed {
+		c.mu.Unlock()
+		return WrapConnectionError("connect", ErrConnectionClosed)
+	}
+	if !c.reconnecting {
+		c.setStateLocked(StateConnecting, nil)
+	}
 
 	if c.ws != nil {
 		c.ws.Close()
 		c.ws = nil
 	}
 
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
-	}
-
-	conn, _, err := dialer.Dial(c.wsURL, c.requestHeader)
+	conn, _, err := c.newDialer().DialContext(c.ctx, c.wsURL, c.requestHeader)
 	if err != nil {
-		c.state = StateDisconnected // Set state back to disconnected on failure
+		err = fmt.Errorf("failed to connect to WebSocket: %w", err)
+		if !c.reconnecting {
+			c.setStateLocked(StateDisconnected, err) // Set state back to disconnected on failure
+		}
 		c.mu.Unlock()
-		return fmt.Errorf("failed to connect to WebSocket: %w", err)
+		return err
 	}
 
 	c.ws = conn
-	c.state = StateConnected  // Set state to connected after successful connection
 
 	// Setup ping handler to respond to server pings
 	c.ws.SetPingHandler(func(appData string) error {
@@ -109,15 +214,65 @@ func (c *Client) connect() error {
 		}
 		return c.ws.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(10*time.Second))
 	})
-	
-	// Release mutex before calling SendInitMessage to avoid deadlock
+
+	// Pongs prove the connection is alive even when no data is flowing
+	c.ws.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	})
+	c.lastHeartbeat.Store(time.Now().UnixNano())
+
+	// Release mutex before completing the handshake to avoid deadlock
 	c.mu.Unlock()
 
-	return c.SendInitMessage()
+	// The server greets every connection with a session info packet
+	info, err := readServerInfo(conn, c.handshakeTimeout)
+	if err != nil {
+		err = WrapConnectionError("connect.server_info", err)
+		c.abortConnect(conn, err)
+		return err
+	}
+	c.mu.Lock()
+	c.serverInfo = info
+	if c.ws == conn {
+		c.setStateLocked(StateAuthenticating, nil)
+	}
+	c.mu.Unlock()
+	c.logger.Debug("received server info", "session_id", info.SessionID, "release", info.Release)
+
+	if err := c.SendInitMessage(); err != nil {
+		c.abortConnect(conn, err)
+		return err
+	}
+
+	// Only report the connection as usable once the handshake is complete
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ws != conn {
+		return WrapConnectionError("connect", ErrConnectionClosed)
+	}
+	c.setStateLocked(StateConnected, nil)
+	return nil
+}
+
+// abortConnect tears down a connection whose handshake failed with err
+func (c *Client) abortConnect(conn *websocket.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn.Close()
+	if c.ws == conn {
+		c.ws = nil
+		if c.reconnecting {
+			c.setStateLocked(StateReconnecting, err)
+		} else {
+			c.setStateLocked(StateDisconnected, err)
+		}
+	}
 }
 
 // pingHandler sends periodic ping messages to keep the connection alive
 func (c *Client) pingHandler() {
+	defer c.loops.Done()
+
 	ticker := time.NewTicker(c.pingInterval)
 	defer ticker.Stop()
 
@@ -128,25 +283,26 @@ func (c *Client) pingHandler() {
 			// Check connection state before sending ping
 			if c.ws == nil || c.state != StateConnected {
 				c.mu.Unlock()
-				slog.Warn("skipping ping - connection not ready", "state", c.state)
+				c.logger.Warn("skipping ping - connection not ready", "state", c.state)
 				continue
 			}
-			
+
 			err := c.ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(c.writeTimeout))
 			c.mu.Unlock()
 
 			if err != nil {
-				slog.Error("ping error", "error", err, "retry_count", c.retryCount)
-				
+				c.logger.Error("ping error", "error", err, "retry_count", c.retryCount)
+
 				// Don't attempt reconnection if already reconnecting
 				c.mu.Lock()
-				shouldReconnect := !c.reconnecting && c.state != StateConnecting
+				shouldReconnect := !c.reconnecting
 				c.mu.Unlock()
-				
+
 				if shouldReconnect {
+					cause := WrapConnectionError("ping", err)
 					go func() {
-						if err := c.reconnect(); err != nil {
-							slog.Error("failed to reconnect after ping error", "error", err)
+						if err := c.reconnect(cause); err != nil {
+							c.logger.Error("failed to reconnect after ping error", "error", err)
 						}
 					}()
 				}
@@ -154,7 +310,7 @@ func (c *Client) pingHandler() {
 				// Reset retry count on successful ping
 				c.mu.Lock()
 				if c.retryCount > 0 {
-					slog.Debug("ping successful, resetting retry count", "previous_retries", c.retryCount)
+					c.logger.Debug("ping successful, resetting retry count", "previous_retries", c.retryCount)
 					c.retryCount = 0
 				}
 				c.mu.Unlock()
@@ -165,93 +321,160 @@ func (c *Client) pingHandler() {
 	}
 }
 
-// reconnect attempts to reconnect to the WebSocket server
-func (c *Client) reconnect() error {
+// heartbeatWatchdog triggers a reconnect when the server stops sending ~h~
+// heartbeats, which a half-open connection does not report as an error
+func (c *Client) heartbeatWatchdog() {
+	defer c.loops.Done()
+
+	ticker := time.NewTicker(c.heartbeatTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			connected := c.ws != nil && c.state == StateConnected
+			c.mu.Unlock()
+
+			silence := time.Since(time.Unix(0, c.lastHeartbeat.Load()))
+			if !connected || silence <= c.heartbeatTimeout {
+				continue
+			}
+
+			c.logger.Warn("no heartbeat from server, reconnecting", "silence", silence)
+			cause := WrapConnectionError("heartbeat_watchdog",
+				fmt.Errorf("%w: no heartbeat for %v", ErrStaleConnection, silence.Round(time.Millisecond)))
+			if err := c.reconnect(cause); err != nil {
+				c.logger.Error("failed to reconnect stale connection", "error", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// LastHeartbeat returns when the last server heartbeat arrived, or when the
+// current connection was established if none has arrived yet
+func (c *Client) LastHeartbeat() time.Time {
+	return time.Unix(0, c.lastHeartbeat.Load())
+}
+
+// reconnect re-establishes the connection lost because of cause according to
+// the reconnect policy. It is the only reconnect path: concurrent callers wait
+// for the reconnect in progress and share its result.
+func (c *Client) reconnect(cause error) error {
 	c.mu.Lock()
-	
-	// Prevent multiple reconnection attempts
+	if c.closed {
+		c.mu.Unlock()
+		return WrapConnectionError("reconnect", ErrConnectionClosed)
+	}
+
+	// Join a reconnection already in progress
 	if c.reconnecting {
+		done := c.reconnectDone
 		c.mu.Unlock()
-		return fmt.Errorf("reconnection already in progress")
+		select {
+		case <-done:
+		case <-c.done:
+			return WrapConnectionError("reconnect", ErrConnectionClosed)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.reconnectErr
 	}
-	
+
 	c.reconnecting = true
-	c.state = StateConnecting
-	
+	c.reconnectDone = make(chan struct{})
+	c.setStateLocked(StateReconnecting, cause)
+
 	// Close existing connection if any
 	if c.ws != nil {
 		c.ws.Close()
 		c.ws = nil
 	}
-	
+
+	c.mu.Unlock()
+
+	err := c.reconnectWithPolicy()
+
+	c.mu.Lock()
+	c.reconnecting = false
+	c.reconnectErr = err
+	close(c.reconnectDone)
 	c.mu.Unlock()
-	
-	defer func() {
+	return err
+}
+
+func (c *Client) reconnectWithPolicy() error {
+	for attempt := 1; ; attempt++ {
 		c.mu.Lock()
-		c.reconnecting = false
-		c.mu.Unlock()
-	}()
-	
-	// Exponential backoff with jitter
-	for attempt := 0; attempt < c.maxRetries; attempt++ {
 		c.retryCount++
-		
-		// Calculate delay: 2^attempt seconds, capped at 30 seconds
-		delay := time.Duration(1<<uint(attempt)) * time.Second
-		if delay > 30*time.Second {
-			delay = 30 * time.Second
-		}
-		
-		// Add jitter (±25% of delay)
-		jitter := time.Duration(float64(delay) * 0.25 * (2*float64(time.Now().UnixNano()%2) - 1))
-		delay += jitter
-		
-		if attempt > 0 {
-			slog.Info("reconnection attempt", 
-				"attempt", attempt+1, 
-				"max_retries", c.maxRetries,
-				"delay", delay)
-			time.Sleep(delay)
-		}
-		
+		c.mu.Unlock()
+
 		// Attempt to connect
 		err := c.connect()
+		if errors.Is(err, ErrConnectionClosed) {
+			return err
+		}
 		if err != nil {
-			slog.Error("reconnection failed", 
-				"attempt", attempt+1, 
+			c.logger.Error("reconnection failed",
+				"attempt", attempt,
 				"error", err)
+
+			if !c.reconnectPolicy.ShouldRetry(attempt, err) {
+				err = WrapConnectionError("reconnect",
+					fmt.Errorf("%w after %d attempts: %v", ErrReconnectFailed, attempt, err))
+				c.mu.Lock()
+				c.setStateLocked(StateFailed, err)
+				c.mu.Unlock()
+				// Close the client even when no read loop is running
+				c.shutdown(err)
+				return err
+			}
+
+			delay := c.reconnectPolicy.NextDelay(attempt)
+			c.logger.Info("reconnection attempt",
+				"attempt", attempt+1,
+				"delay", delay)
+			if !c.sleep(delay) {
+				return WrapConnectionError("reconnect", ErrConnectionClosed)
+			}
 			continue
 		}
-		
+
 		// Connection successful
 		c.mu.Lock()
-		c.state = StateConnected
 		c.retryCount = 0
 		c.lastConnectTime = time.Now()
 		c.mu.Unlock()
-		
-		slog.Info("reconnection successful", "attempt", attempt+1)
-		
+
+		c.reconnectPolicy.Reset()
+		c.logger.Info("reconnection successful", "attempt", attempt)
+
+		// Re-create chart and quote sessions lost with the previous connection
+		report := c.restoreSubscriptions()
+		c.mu.Lock()
+		onRestore := c.onRestore
+		c.mu.Unlock()
+		if onRestore != nil {
+			onRestore(report)
+		}
+		c.events.publish(SubscriptionsRestoredEvent{report}, c.done)
+		c.privateSessions.broadcast(SubscriptionsRestoredEvent{report}, c.done)
+
 		// Call reconnection callback if set
 		if c.onReconnect != nil {
 			if err := c.onReconnect(); err != nil {
-				slog.Error("reconnection callback failed", "error", err)
+				c.logger.Error("reconnection callback failed", "error", err)
 			}
 		}
-		
+
 		return nil
 	}
-	
-	// All attempts failed
-	c.mu.Lock()
-	c.state = StateDisconnected
-	c.mu.Unlock()
-	
-	return fmt.Errorf("failed to reconnect after %d attempts", c.maxRetries)
 }
 
 func (c *Client) Reconnect() error {
-	return c.reconnect()
+	return c.reconnect(nil)
 }
 
 // SetReconnectCallback sets a callback function to be called after successful reconnection
@@ -276,8 +499,10 @@ func (c *Client) IsConnected() bool {
 }
 
 func (c *Client) SendInitMessage() error {
-	// Should be initialized AuthTokenManager first
-	authToken := GetAuthTokenManager().GetToken()
+	authToken, err := c.resolveAuthToken()
+	if err != nil {
+		return err
+	}
 
 	if err := SendSetAuthTokenMessage(c, authToken); err != nil {
 		return err
@@ -290,148 +515,383 @@ func (c *Client) SendInitMessage() error {
 	return nil
 }
 
+// tokenFetcher is implemented by token providers that report acquisition
+// errors, such as AuthTokenManager
+type tokenFetcher interface {
+	FetchTokenContext(ctx context.Context) (string, error)
+}
+
+// resolveAuthToken returns the token to authenticate the connection with, using
+// the client's provider or the package-level AuthTokenManager
+func (c *Client) resolveAuthToken() (string, error) {
+	c.mu.Lock()
+	provider := c.tokenProvider
+	c.mu.Unlock()
+	if provider == nil {
+		m := defaultAuthTokenManager()
+		if m == nil {
+			return "", WrapAuthError("send_init_message",
+				errors.New("no token provider configured, use WithTokenProvider or InitAuthTokenManager"))
+		}
+		provider = m
+	}
+
+	if fetcher, ok := provider.(tokenFetcher); ok {
+		token, err := fetcher.FetchTokenContext(c.ctx)
+		if err != nil {
+			if IsAuthError(err) {
+				return "", err
+			}
+			return "", WrapAuthError("send_init_message", err)
+		}
+		return token, nil
+	}
+	return provider.GetToken(), nil
+}
+
+// tokenRotationNotifier is implemented by token providers that refresh tokens
+// in the background, such as AuthTokenManager
+type tokenRotationNotifier interface {
+	OnTokenRotated(listener func(TokenRotation)) func()
+}
+
+// tokenAutoRefresher is implemented by token providers that can renew their
+// token before it expires, such as AuthTokenManager
+type tokenAutoRefresher interface {
+	StartAutoRefresh(ctx context.Context, lead time.Duration)
+}
+
+// watchTokenRotations pushes rotated tokens over the open connection and
+// renews the provider's token in the background until the client shuts down
+func (c *Client) watchTokenRotations() {
+	var provider AuthTokenManagerInterface = c.tokenProvider
+	if provider == nil {
+		if m := defaultAuthTokenManager(); m != nil {
+			provider = m
+		}
+	}
+	if notifier, ok := provider.(tokenRotationNotifier); ok {
+		c.stopTokenRotations = notifier.OnTokenRotated(c.handleTokenRotation)
+	}
+	if refresher, ok := provider.(tokenAutoRefresher); ok && c.tokenRefreshLead > 0 {
+		refresher.StartAutoRefresh(c.ctx, c.tokenRefreshLead)
+	}
+}
+
+// handleTokenRotation sends a freshly rotated token to the server without reconnecting
+func (c *Client) handleTokenRotation(rotation TokenRotation) {
+	c.mu.Lock()
+	connected := c.state == StateConnected && c.ws != nil
+	callback := c.onTokenRotated
+	c.mu.Unlock()
+	c.tokenRotations.Add(1)
+
+	// Connections being established pick up the new token in SendInitMessage
+	var err error
+	if connected {
+		if err = SendSetAuthTokenMessage(c, rotation.Token); err != nil {
+			err = WrapAuthError("token_rotation", err)
+			c.logger.Error("failed to push rotated auth token", "error", err)
+		} else {
+			c.logger.Info("auth token rotated", "expires_at", rotation.ExpiresAt)
+		}
+	}
+
+	if callback != nil {
+		callback(rotation, err)
+	}
+}
+
+// SetTokenRotatedCallback sets a callback function to be called every time the
+// token provider rotates the auth token. err is non-nil when the new token
+// could not be sent over the open connection.
+func (c *Client) SetTokenRotatedCallback(callback func(rotation TokenRotation, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onTokenRotated = callback
+}
+
+// TokenRotations returns how many auth token rotations the client has observed
+func (c *Client) TokenRotations() uint64 {
+	return c.tokenRotations.Load()
+}
+
+// ReadMessage reads messages until the client is closed, forwarding raw
+// responses to dataChan (which may be nil) and typed events to Events subscribers
 func (c *Client) ReadMessage(dataChan chan<- TVResponse) error {
-	retries := 0
+	return c.ReadMessageContext(context.Background(), dataChan)
+}
+
+// Run reads messages and publishes them to Events subscribers until ctx is
+// cancelled or the client is closed. It returns the same error as Wait.
+func (c *Client) Run(ctx context.Context) error {
+	return c.ReadMessageContext(ctx, nil)
+}
+
+// ReadMessageContext is like ReadMessage, but cancelling ctx closes the client
+// and makes the read loop return promptly with the context error
+func (c *Client) ReadMessageContext(ctx context.Context, dataChan chan<- TVResponse) error {
+	c.mu.Lock()
+	if c.closed {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.loops.Add(1)
+	c.mu.Unlock()
+	defer c.loops.Done()
+
+	// Closing the client unblocks the pending read
+	stop := context.AfterFunc(ctx, func() {
+		c.shutdown(ctx.Err())
+	})
+	defer stop()
+
+	err := c.readMessages(dataChan)
+	if err != nil {
+		c.shutdown(err)
+	}
+	return c.terminalError(err)
+}
+
+// readMessages runs the read loop, returning nil once the client is closed
+func (c *Client) readMessages(dataChan chan<- TVResponse) error {
 	for {
 		c.mu.Lock()
-		if c.ws == nil || c.state != StateConnected {
-			shouldAttemptReconnect := !c.reconnecting && retries < c.maxRetries
+		if c.closed {
 			c.mu.Unlock()
-			
-			if shouldAttemptReconnect {
-				retries++
-				slog.Info("connection lost, attempting reconnect",
-					"attempt", retries,
-					"max_retries", c.maxRetries)
-
-				if err := c.reconnect(); err != nil {
-					slog.Error("reconnection attempt failed",
-						"attempt", retries,
-						"error", err)
-					time.Sleep(time.Duration(retries) * time.Second)
-					continue
-				}
-			} else {
-				if retries >= c.maxRetries {
-					return WrapConnectionError("read_message.max_retries", ErrReconnectFailed)
-				}
-				// If reconnection is already in progress, wait a bit
-				time.Sleep(100 * time.Millisecond)
-			}
-			continue
+			return nil
 		}
 		ws := c.ws // Store local copy of ws
+		connected := ws != nil && c.state == StateConnected
 		c.mu.Unlock()
 
+		if !connected {
+			if err := c.reconnect(nil); err != nil {
+				return c.readLoopError(err)
+			}
+			continue
+		}
+
+		// A half-open connection never errors, so every read must complete in time
+		if err := ws.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			c.logger.Debug("failed to set read deadline", "error", err)
+		}
 		_, message, err := ws.ReadMessage()
 		if err != nil {
+			select {
+			case <-c.done:
+				return nil
+			default:
+			}
+
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-				slog.Error("connection closed", "error", err)
+				c.logger.Error("connection closed", "error", err)
+				c.shutdown(nil)
 				return nil
 			}
 
-			// Check if we should attempt reconnect
+			// The connection may already have been replaced by another reconnect path
 			c.mu.Lock()
-			shouldAttemptReconnect := !c.reconnecting && retries < c.maxRetries
+			replaced := c.ws != ws
 			c.mu.Unlock()
-			
-			if shouldAttemptReconnect {
-				retries++
-				slog.Error("connection error, attempting reconnect",
-					"attempt", retries,
-					"max_retries", c.maxRetries,
-					"error", err)
-
-				if err := c.reconnect(); err != nil {
-					slog.Error("reconnection attempt failed",
-						"attempt", retries,
-						"error", err)
-					time.Sleep(time.Duration(retries) * time.Second)
-					continue
-				}
-
-				slog.Info("successfully reconnected",
-					"attempt", retries)
-				continue
-			} else {
-				if retries >= c.maxRetries {
-					return fmt.Errorf("error reading message after %d reconnection attempts: %w", retries, err)
-				}
-				// If reconnection is already in progress, wait a bit
-				time.Sleep(100 * time.Millisecond)
+			if replaced {
 				continue
 			}
-		}
-
-		// Reset retry counter on successful message
-		retries = 0
 
-		// Handle heartbeat messages
-		if heartbeatRegex.Match(message) {
-			c.mu.Lock()
-			if c.ws == nil {
-				c.mu.Unlock()
-				continue
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = fmt.Errorf("%w: no data for %v: %v", ErrStaleConnection, c.readTimeout, err)
 			}
-			err := c.ws.WriteMessage(websocket.TextMessage, message)
-			c.mu.Unlock()
-
-			if err != nil {
-				slog.Error("error sending heartbeat response", "error", err)
-				if err := c.reconnect(); err != nil {
-					slog.Error("reconnection failed after heartbeat error", "error", err)
-				}
-				continue
+			c.logger.Error("connection error, attempting reconnect", "error", err)
+			if err := c.reconnect(WrapConnectionError("read_message", err)); err != nil {
+				return c.readLoopError(err)
 			}
 			continue
 		}
 
-		// Process data messages
-		parts := strings.Split(string(message), "~m~")
-		for _, part := range parts {
-			if strings.HasPrefix(part, "{") {
+		packets, err := DecodeFrame(message)
+		if err != nil {
+			c.logger.Error("failed to decode frame", "error", err)
+			c.publishParseError(message, err)
+			continue
+		}
+
+		for _, packet := range packets {
+			switch packet.Kind {
+			case PacketHeartbeat:
+				c.lastHeartbeat.Store(time.Now().UnixNano())
+				// Echo heartbeats back so the server keeps the session alive
+				if err := c.sendHeartbeat(packet.Payload); err != nil {
+					c.logger.Error("error sending heartbeat response", "error", err)
+					if err := c.reconnect(WrapConnectionError("heartbeat", err)); err != nil {
+						c.logger.Error("reconnection failed after heartbeat error", "error", err)
+						return c.readLoopError(err)
+					}
+				}
+
+			case PacketJSON:
 				var response TVResponse
-				if err := json.Unmarshal([]byte(part), &response); err != nil {
-					slog.Error("failed to unmarshal message", "error", err)
+				if err := json.Unmarshal(packet.Payload, &response); err != nil {
+					c.logger.Error("failed to unmarshal message", "error", err)
+					c.publishParseError(packet.Payload, WrapMessageError("read_message.unmarshal", err))
 					continue
 				}
-				select {
-				case dataChan <- response:
-				case <-c.done:
-					slog.Error("dataChan closed", "response", response)
-					return nil
+				// Session info packets are the only JSON objects without a method
+				if response.Method == "" && !bytes.Equal(packet.Payload, []byte("{}")) {
+					c.storeServerInfo(packet.Payload)
+					continue
+				}
+				// Traffic of internal sessions only goes to their owner
+				if session := responseSession(response); session != "" && c.privateSessions.isPrivate(session) {
+					c.privateSessions.deliver(session, c.annotateEvent(DecodeEvent(response)), c.done)
+					continue
+				}
+				if dataChan != nil {
+					select {
+					case dataChan <- response:
+					case <-c.done:
+						return nil
+					}
 				}
+				// Chart data is always decoded to keep the bar series current
+				subscribed := c.events.hasSubscribers()
+				if subscribed || response.Method == MethodTimescaleUpdate || response.Method == MethodDataUpdate {
+					if event, ok := c.dropStaleSeries(c.annotateEvent(DecodeEvent(response))); ok {
+						barEvents := c.applyBars(event)
+						if subscribed {
+							c.events.publish(event, c.done)
+							for _, e := range barEvents {
+								c.events.publish(e, c.done)
+							}
+						}
+					}
+				}
+
+			case PacketSessionInfo:
+				c.storeServerInfo(packet.Payload)
 			}
 		}
 	}
 }
 
-// Close closes the WebSocket connection and stops the ping handler
-func (c *Client) Close() error {
-	c.cancel() // Cancel context
+// readLoopError maps a failed reconnect to the read loop result; a reconnect
+// interrupted by Close ends the loop without an error
+func (c *Client) readLoopError(err error) error {
+	if errors.Is(err, ErrConnectionClosed) {
+		return nil
+	}
+	return err
+}
 
+// storeServerInfo records a session info packet received outside of the handshake
+func (c *Client) storeServerInfo(payload []byte) {
+	info, err := ParseServerInfo(payload)
+	if err != nil {
+		c.logger.Error("failed to parse server info", "error", err)
+		c.publishParseError(payload, err)
+		return
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.serverInfo = info
+	c.mu.Unlock()
+}
 
-	if c.state == StateDisconnected {
-		return nil
+// publishParseError reports a frame or payload that could not be decoded to
+// Events subscribers
+func (c *Client) publishParseError(raw []byte, err error) {
+	if c.events.hasSubscribers() {
+		c.events.publish(ParseErrorEvent{Raw: append([]byte(nil), raw...), Err: err}, c.done)
 	}
+}
 
-	c.state = StateDisconnected
-	if c.ws != nil {
-		if err := c.ws.WriteControl(
-			websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
-			time.Now().Add(time.Second),
-		); err != nil {
-			slog.Error("error sending close message", "error", err)
+// sendHeartbeat echoes a heartbeat payload back to the server
+func (c *Client) sendHeartbeat(payload []byte) error {
+	return c.enqueue(EncodeFrame(string(payload)), "heartbeat", priorityHeartbeat)
+}
+
+// Close closes the WebSocket connection and stops the ping handler and read
+// loops. It is safe to call Close more than once.
+func (c *Client) Close() error {
+	return c.shutdown(nil)
+}
+
+// shutdown tears the client down once, recording cause as the terminal error
+func (c *Client) shutdown(cause error) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
+		if c.stopTokenRotations != nil {
+			c.stopTokenRotations()
 		}
 
-		err := c.ws.Close()
-		c.ws = nil
-		return err
+		c.mu.Lock()
+		c.closed = true
+		c.err = cause
+		// A client whose reconnect policy gave up stays in StateFailed
+		if c.state != StateFailed {
+			c.setStateLocked(StateClosed, cause)
+		}
+		c.mu.Unlock()
+
+		// Flush queued messages before the close frame
+		c.writes.close()
+		select {
+		case <-c.writes.stopped:
+		case <-time.After(flushTimeout):
+			c.logger.Warn("timed out flushing the write queue")
+		}
+
+		c.mu.Lock()
+		if c.ws != nil {
+			if werr := c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(time.Second),
+			); werr != nil {
+				c.logger.Debug("error sending close message", "error", werr)
+			}
+			err = c.ws.Close()
+			c.ws = nil
+		}
+		c.mu.Unlock()
+
+		close(c.done)
+	})
+	return err
+}
+
+// Wait blocks until the client has been closed and its ping, read and write
+// loops have exited. It returns nil after Close, the context error when the client's
+// context was cancelled, or the error that stopped the read loop.
+func (c *Client) Wait() error {
+	<-c.done
+	c.loops.Wait()
+	<-c.writes.stopped
+	return c.terminalError(nil)
+}
+
+// terminalError returns the error the client was shut down with, or fallback
+// when it is still running
+func (c *Client) terminalError(fallback error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.err
+	}
+	return fallback
+}
+
+// sleep waits for d and reports false if the client was closed in the meantime
+func (c *Client) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.done:
+		return false
 	}
-	return nil
 }
 
 // defaultHeaders returns the default HTTP headers for the WebSocket connection
@@ -0,0 +1,524 @@
+package tvwsclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+const testSymbol = "BINANCE:BTCUSDT"
+
+// newTestClient connects a client to srv, applying options after the defaults
+func newTestClient(t *testing.T, srv *tvwstest.Server, options ...Option) *Client {
+	t.Helper()
+
+	tokens := NewAuthTokenManager(nil)
+	tokens.SetToken("test_token")
+
+	client, err := NewClient(append([]Option{
+		WithURL(srv.URL),
+		WithTokenProvider(tokens),
+		WithMaxRetries(1),
+	}, options...)...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// waitForEvent returns the first event of type T, failing the test on timeout
+func waitForEvent[T Event](t *testing.T, events <-chan Event) T {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if e, ok := event.(T); ok {
+				return e
+			}
+		case <-timeout:
+			var zero T
+			t.Fatalf("timed out waiting for %T", zero)
+			return zero
+		}
+	}
+}
+
+func TestClientHandshake(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+
+	info := client.ServerInfo()
+	if info == nil || info.Release != "tvwstest" {
+		t.Fatalf("ServerInfo() = %+v, want release tvwstest", info)
+	}
+	if !client.IsConnected() {
+		t.Errorf("IsConnected() = false after NewClient")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := srv.WaitForMessages(ctx, "set_auth_token", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages[0].ParamString(0); got != "test_token" {
+		t.Errorf("set_auth_token token = %q, want test_token", got)
+	}
+	if _, err := srv.WaitForMessages(ctx, "set_locale", 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientStoresServerInfoAfterHandshake(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	responses := make(chan TVResponse, 1)
+	go client.ReadMessage(responses)
+
+	srv.Send(`{"session_id":"late_session","release":"late_release","timestamp":1}`, `{"m":"quote_completed","p":["qs_test","BINANCE:BTCUSDT"]}`)
+
+	select {
+	case response := <-responses:
+		if response.Method != "quote_completed" {
+			t.Errorf("first response = %+v, want quote_completed", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for quote_completed")
+	}
+	if info := client.ServerInfo(); info == nil || info.Release != "late_release" || info.SessionID != "late_session" {
+		t.Errorf("ServerInfo() = %+v, want the late session info", info)
+	}
+}
+
+func TestClientPublishesParseErrors(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	corrupted := `~m~99~m~{"m":"qsd"}`
+	srv.SendRaw(corrupted)
+	frameErr := waitForEvent[ParseErrorEvent](t, events)
+	if string(frameErr.Raw) != corrupted || !errors.Is(frameErr.Err, ErrInvalidMessage) {
+		t.Errorf("frame ParseErrorEvent = %+v, want the corrupted frame", frameErr)
+	}
+
+	info := `{"session_id":42}`
+	srv.Send(info)
+	infoErr := waitForEvent[ParseErrorEvent](t, events)
+	if string(infoErr.Raw) != info || !errors.Is(infoErr.Err, ErrInvalidMessage) {
+		t.Errorf("server info ParseErrorEvent = %+v, want the session info payload", infoErr)
+	}
+
+	// The connection keeps working after both failures
+	srv.Send(`{"m":"quote_completed","p":["qs_test","BINANCE:BTCUSDT"]}`)
+	waitForEvent[QuoteCompletedEvent](t, events)
+}
+
+func TestClientAnswersHeartbeats(t *testing.T) {
+	srv := tvwstest.NewServer(tvwstest.WithHeartbeatInterval(20 * time.Millisecond))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	go client.ReadMessage(nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.HeartbeatsAcked() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("HeartbeatsAcked() = %d, want at least 2", srv.HeartbeatsAcked())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientChartEvents(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := tvwstest.GenerateBars(start, time.Minute, 10)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatalf("SubscriptionChartSessionSymbol() error = %v", err)
+	}
+
+	resolved := waitForEvent[SymbolResolvedEvent](t, events)
+	if resolved.SymbolInfo.ProName != testSymbol {
+		t.Errorf("resolved symbol = %q, want %q", resolved.SymbolInfo.ProName, testSymbol)
+	}
+
+	snapshot := waitForEvent[TimescaleUpdateEvent](t, events)
+	if got := len(snapshot.Data.SDS1.S); got != 5 {
+		t.Fatalf("snapshot has %d bars, want 5", got)
+	}
+	if got, want := int64(snapshot.Data.SDS1.S[4].V[0]), bars[9].Time; got != want {
+		t.Errorf("last bar time = %d, want %d", got, want)
+	}
+	waitForEvent[SeriesCompletedEvent](t, events)
+
+	next := tvwstest.Bar{Time: bars[9].Time + 60, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10}
+	srv.PushBar(testSymbol, next)
+
+	update := waitForEvent[BarUpdateEvent](t, events)
+	if got := update.Data.SDS1.S; len(got) != 1 || got[0].I != 5 || got[0].V[4] != 1.5 {
+		t.Errorf("du series = %+v, want index 5 closing at 1.5", got)
+	}
+}
+
+func TestSubscriptionChartSessionSymbolInterval(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	if err := SubscriptionChartSessionSymbol(client, "cs_bad", testSymbol, "1X", 5); err == nil {
+		t.Error("SubscriptionChartSessionSymbol() accepted interval 1X")
+	}
+	if _, ok := client.subscriptions.get("cs_bad"); ok {
+		t.Error("session with an invalid interval was registered")
+	}
+	if err := SendCreateSeriesMessage(client, "cs_bad", "", 5); err == nil {
+		t.Error("SendCreateSeriesMessage() accepted an empty interval")
+	}
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "4h", 5); err != nil {
+		t.Fatalf("SubscriptionChartSessionSymbol() error = %v", err)
+	}
+	if sub, _ := client.subscriptions.get("cs_test"); sub.Interval != "240" {
+		t.Errorf("registered interval = %q, want 240", sub.Interval)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msgs, err := srv.WaitForMessages(ctx, "create_series", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msgs[0].Params[4]; got != "240" {
+		t.Errorf("create_series interval = %v, want 240", got)
+	}
+}
+
+func TestClientQuoteEvents(t *testing.T) {
+	srv := tvwstest.NewServer(tvwstest.WithQuote(testSymbol, map[string]interface{}{"lp": 42000.5}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionQuoteSessionSymbol(client, "qs_test", testSymbol); err != nil {
+		t.Fatalf("SubscriptionQuoteSessionSymbol() error = %v", err)
+	}
+
+	quote := waitForEvent[QuoteUpdateEvent](t, events)
+	if quote.Data.Name != testSymbol || quote.Data.Status != "ok" {
+		t.Errorf("qsd = %+v, want ok status for %s", quote.Data, testSymbol)
+	}
+	waitForEvent[QuoteCompletedEvent](t, events)
+
+	srv.PushQuote(testSymbol, map[string]interface{}{"lp": 42001.0})
+	quote = waitForEvent[QuoteUpdateEvent](t, events)
+	if quote.Data.Values.LastPrice != 42001.0 {
+		t.Errorf("lp = %v, want 42001", quote.Data.Values.LastPrice)
+	}
+}
+
+func TestClientReconnectRestoresSubscriptions(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, time.Minute, 10)))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_test", testSymbol, "1", 5); err != nil {
+		t.Fatalf("SubscriptionChartSessionSymbol() error = %v", err)
+	}
+	if err := SubscriptionQuoteSessionSymbol(client, "qs_test", testSymbol); err != nil {
+		t.Fatalf("SubscriptionQuoteSessionSymbol() error = %v", err)
+	}
+	if _, err := srv.WaitForMessages(ctx, "create_series", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+
+	restored := waitForEvent[SubscriptionsRestoredEvent](t, events)
+	if len(restored.Restored) != 2 || len(restored.Failed) != 0 {
+		t.Fatalf("restore report = %+v, want 2 restored subscriptions", restored.RestoreReport)
+	}
+	if got := srv.Connections(); got != 2 {
+		t.Errorf("Connections() = %d, want 2", got)
+	}
+	if _, err := srv.WaitForMessages(ctx, "create_series", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitForMessages(ctx, "quote_add_symbols", 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientReconnectRestoresQuoteSession(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	descriptor := SymbolDescriptor{Symbol: testSymbol, Session: SessionExtended}
+	if err := SendQuoteCreateSessionMessage(client, "qs_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendQuoteSetFieldsMessage(client, "qs_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendQuoteAddSymbolsMessage(client, "qs_test", descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitForMessages(ctx, "quote_add_symbols", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+
+	restored := waitForEvent[SubscriptionsRestoredEvent](t, events)
+	if len(restored.Restored) != 1 || len(restored.Failed) != 0 {
+		t.Fatalf("restore report = %+v, want 1 restored subscription", restored.RestoreReport)
+	}
+	if _, err := srv.WaitForMessages(ctx, "quote_set_fields", 2); err != nil {
+		t.Fatal(err)
+	}
+	added, err := srv.WaitForMessages(ctx, "quote_add_symbols", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := added[1].ParamString(1); got != descriptor.String() {
+		t.Errorf("restored symbol = %q, want %q", got, descriptor.String())
+	}
+}
+
+func TestClientReconnectSkipsDeletedQuoteSession(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	for _, session := range []string{"qs_kept", "qs_deleted"} {
+		if err := SendQuoteCreateSessionMessage(client, session); err != nil {
+			t.Fatal(err)
+		}
+		if err := SendQuoteAddSymbolsMessageWithType(client, session, testSymbol, OnlySymbol); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SendQuoteDeleteSessionMessage(client, "qs_deleted"); err != nil {
+		t.Fatalf("SendQuoteDeleteSessionMessage() error = %v", err)
+	}
+	if _, err := srv.WaitForMessages(ctx, "quote_delete_session", 1); err != nil {
+		t.Fatal(err)
+	}
+	if subs := client.Subscriptions(); len(subs) != 1 || subs[0].SessionID != "qs_kept" {
+		t.Fatalf("Subscriptions() = %+v, want only qs_kept", subs)
+	}
+
+	srv.DropConnections()
+
+	restored := waitForEvent[SubscriptionsRestoredEvent](t, events)
+	if len(restored.Restored) != 1 || restored.Restored[0].SessionID != "qs_kept" {
+		t.Errorf("restored = %v, want only qs_kept", restored.Restored)
+	}
+	created, err := srv.WaitForMessages(ctx, "quote_create_session", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := created[2].ParamString(0); got != "qs_kept" || len(created) != 3 {
+		t.Errorf("re-created quote sessions = %v, want only qs_kept", created[2:])
+	}
+}
+
+func TestClientRunStopsOnCancel(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := make(chan error, 1)
+	go func() { result <- client.Run(ctx) }()
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancellation")
+	}
+	if err := client.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+	if client.IsConnected() {
+		t.Errorf("IsConnected() = true after cancellation")
+	}
+}
+
+func TestClientCloseIsIdempotent(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	result := make(chan error, 1)
+	go func() { result <- client.ReadMessage(nil) }()
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("ReadMessage() error = %v, want nil after Close", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReadMessage() did not return after Close")
+	}
+	if err := client.Wait(); err != nil {
+		t.Errorf("Wait() error = %v, want nil after Close", err)
+	}
+	if err := client.Run(context.Background()); err != nil {
+		t.Errorf("Run() after Close error = %v, want nil", err)
+	}
+}
+
+func TestNewClientContextCancel(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	tokens := NewAuthTokenManager(nil)
+	tokens.SetToken("test_token")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := NewClientContext(ctx, WithURL(srv.URL), WithTokenProvider(tokens))
+	if err != nil {
+		t.Fatalf("NewClientContext() error = %v", err)
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after the parent context was cancelled")
+	}
+}
+
+func TestClientRenewsExpiringToken(t *testing.T) {
+	freshToken := testJWT(time.Now().Add(time.Hour))
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"` + freshToken + `"`))
+	}))
+	defer tokenServer.Close()
+
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	tokens := NewAuthTokenManager(NewTVHttpClient(tokenServer.URL, "device", "session", "sign"))
+	tokens.SetToken(testJWT(time.Now().Add(2 * time.Second)))
+	client, err := NewClient(WithURL(srv.URL), WithTokenProvider(tokens), WithTokenRefreshLead(time.Second))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+	go client.ReadMessage(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	messages, err := srv.WaitForMessages(ctx, "set_auth_token", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages[1].ParamString(0); got != freshToken {
+		t.Errorf("renewed token = %q, want the refreshed token", got)
+	}
+	if got := client.TokenRotations(); got != 1 {
+		t.Errorf("TokenRotations() = %d, want 1", got)
+	}
+}
+
+func TestHeartbeatWatchdogReconnects(t *testing.T) {
+	srv := tvwstest.NewServer(tvwstest.WithHeartbeatInterval(0))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithHeartbeatTimeout(200*time.Millisecond))
+
+	recorder := &stateRecorder{}
+	client.OnStateChange(recorder.record)
+	go client.Run(context.Background())
+
+	stale := recorder.waitFor(t, StateReconnecting)
+	if !errors.Is(stale.Err, ErrStaleConnection) {
+		t.Errorf("reconnect cause = %v, want ErrStaleConnection", stale.Err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.WaitForConnections(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadDeadlineDetectsSilentConnection(t *testing.T) {
+	srv := tvwstest.NewServer(tvwstest.WithHeartbeatInterval(0))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithHeartbeatTimeout(0), WithReadTimeout(200*time.Millisecond))
+
+	recorder := &stateRecorder{}
+	client.OnStateChange(recorder.record)
+	go client.Run(context.Background())
+
+	stale := recorder.waitFor(t, StateReconnecting)
+	if !errors.Is(stale.Err, ErrStaleConnection) {
+		t.Errorf("reconnect cause = %v, want ErrStaleConnection", stale.Err)
+	}
+}
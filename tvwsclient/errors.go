@@ -15,6 +15,7 @@ var (
 	ErrRateLimitExceeded    = errors.New("rate limit exceeded")
 	ErrReconnectFailed      = errors.New("reconnection failed")
 	ErrTimeout              = errors.New("operation timeout")
+	ErrStaleConnection      = errors.New("connection is stale")
 )
 
 // TradingViewError wraps errors with additional context
@@ -124,6 +125,7 @@ func IsRetryableError(err error) bool {
 	
 	// Check for specific error types
 	return errors.Is(err, ErrConnectionClosed) || 
+		   errors.Is(err, ErrStaleConnection) || 
 		   errors.Is(err, ErrTimeout) || 
 		   errors.Is(err, ErrRateLimitExceeded)
 }
\ No newline at end of file
@@ -0,0 +1,355 @@
+package tvwsclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Server-side error methods
+const (
+	MethodProtocolError = "protocol_error"
+	MethodCriticalError = "critical_error"
+	MethodSymbolError   = "symbol_error"
+	MethodSeriesError   = "series_error"
+)
+
+// eventBufferSize is the capacity of channels returned by Client.Events
+const eventBufferSize = 256
+
+// Event is a decoded message delivered by Client.Events. The set of
+// implementations is closed, so a type switch over the types below is exhaustive.
+type Event interface {
+	isEvent()
+}
+
+// QuoteUpdateEvent carries a qsd quote update
+type QuoteUpdateEvent struct {
+	*QuoteDataMessage
+}
+
+// QuoteCompletedEvent signals that a quote session finished loading a symbol
+type QuoteCompletedEvent struct {
+	*QuoteCompletedMessage
+}
+
+// TimescaleUpdateEvent carries a snapshot of bars for a chart session
+type TimescaleUpdateEvent struct {
+	*TimescaleUpdateMessage
+}
+
+// BarUpdateEvent carries an incremental du bar update for a chart session
+type BarUpdateEvent struct {
+	*DuMessage
+}
+
+// SeriesLoadingEvent signals that the server started loading a series
+type SeriesLoadingEvent struct {
+	*SeriesLoadingMessage
+}
+
+// SeriesCompletedEvent signals that a series finished loading
+type SeriesCompletedEvent struct {
+	*SeriesCompletedMessage
+}
+
+// SymbolResolvedEvent carries the symbol information for a chart series
+type SymbolResolvedEvent struct {
+	*SymbolResolvedMessage
+}
+
+// StudyDataEvent carries indicator data for a study session
+type StudyDataEvent struct {
+	*StudyDataMessage
+}
+
+// ProtocolErrorEvent is emitted when the server reports an error for a session or request
+type ProtocolErrorEvent struct {
+	Method    string        // protocol_error, critical_error, symbol_error, series_error or study_error
+	SessionID string        // empty when the error is not tied to a session
+	Params    []interface{} // raw parameters of the error message
+	Err       error
+}
+
+// ParseErrorEvent is emitted when a frame, message or session info packet
+// could not be decoded
+type ParseErrorEvent struct {
+	Response TVResponse // zero when the payload was not valid JSON
+	Raw      []byte     // raw frame or payload, only set when it was not valid JSON or framing
+	Err      error
+}
+
+// UnknownEvent carries messages with a method that has no typed event
+type UnknownEvent struct {
+	Response TVResponse
+}
+
+// SubscriptionsRestoredEvent is emitted after the subscription registry was replayed on a new connection
+type SubscriptionsRestoredEvent struct {
+	*RestoreReport
+}
+
+func (QuoteUpdateEvent) isEvent()           {}
+func (QuoteCompletedEvent) isEvent()        {}
+func (TimescaleUpdateEvent) isEvent()       {}
+func (BarUpdateEvent) isEvent()             {}
+func (SeriesLoadingEvent) isEvent()         {}
+func (SeriesCompletedEvent) isEvent()       {}
+func (SymbolResolvedEvent) isEvent()        {}
+func (StudyDataEvent) isEvent()             {}
+func (ProtocolErrorEvent) isEvent()         {}
+func (ParseErrorEvent) isEvent()            {}
+func (UnknownEvent) isEvent()               {}
+func (SubscriptionsRestoredEvent) isEvent() {}
+
+// DecodeEvent converts a raw response into its typed event. Decoding failures
+// are returned as a ParseErrorEvent rather than an error.
+func DecodeEvent(response TVResponse) Event {
+	var (
+		event Event
+		err   error
+	)
+
+	switch response.Method {
+	case MethodQuoteData:
+		var msg *QuoteDataMessage
+		if msg, err = NewQuoteDataMessage(response.Params); err == nil {
+			event = QuoteUpdateEvent{msg}
+		}
+	case MethodQuoteCompleted:
+		var msg *QuoteCompletedMessage
+		if msg, err = NewQuoteCompletedMessage(response.Params); err == nil {
+			event = QuoteCompletedEvent{msg}
+		}
+	case MethodTimescaleUpdate:
+		var msg *TimescaleUpdateMessage
+		if msg, err = NewTimescaleUpdateMessage(response.Params); err == nil {
+			event = TimescaleUpdateEvent{msg}
+		}
+	case MethodDataUpdate:
+		var msg *DuMessage
+		if msg, err = NewDuMessage(response.Params); err == nil {
+			event = BarUpdateEvent{msg}
+		}
+	case MethodSeriesLoading:
+		var msg *SeriesLoadingMessage
+		if msg, err = NewSeriesLoadingMessage(response.Params); err == nil {
+			event = SeriesLoadingEvent{msg}
+		}
+	case MethodSeriesCompleted:
+		var msg *SeriesCompletedMessage
+		if msg, err = NewSeriesCompletedMessage(response.Params); err == nil {
+			msg.Time, msg.TimeMS = response.Time, response.TimeMS
+			event = SeriesCompletedEvent{msg}
+		}
+	case MethodSymbolResolved:
+		var msg *SymbolResolvedMessage
+		if msg, err = NewSymbolResolvedMessage(response.Params); err == nil {
+			event = SymbolResolvedEvent{msg}
+		}
+	case MethodStudyData:
+		var msg *StudyDataMessage
+		if msg, err = NewStudyDataMessage(response.Params); err == nil {
+			event = StudyDataEvent{msg}
+		}
+	case MethodProtocolError, MethodCriticalError, MethodSymbolError, MethodSeriesError, MethodStudyError:
+		event = newProtocolErrorEvent(response)
+	default:
+		event = UnknownEvent{Response: response}
+	}
+
+	if err != nil {
+		return ParseErrorEvent{
+			Response: response,
+			Err:      WrapMessageError("decode_event."+response.Method, err),
+		}
+	}
+	return event
+}
+
+func newProtocolErrorEvent(response TVResponse) ProtocolErrorEvent {
+	event := ProtocolErrorEvent{
+		Method: response.Method,
+		Params: response.Params,
+	}
+
+	// Session-scoped errors carry the session ID first and the reason last
+	var reason string
+	if len(response.Params) > 0 {
+		if s, ok := response.Params[len(response.Params)-1].(string); ok {
+			reason = s
+		}
+	}
+	if len(response.Params) > 1 {
+		if s, ok := response.Params[0].(string); ok {
+			event.SessionID = s
+		}
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("%v", response.Params)
+	}
+
+	code := ErrCodeMessage
+	switch response.Method {
+	case MethodSymbolError:
+		code = ErrCodeSymbol
+	case MethodSeriesError, MethodStudyError:
+		code = ErrCodeSession
+	}
+	event.Err = NewTradingViewError(response.Method, code, reason, nil)
+	return event
+}
+
+// eventHub fans decoded events out to every Events subscriber
+type eventHub struct {
+	mu   sync.RWMutex
+	subs map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	ch  chan Event
+	ctx context.Context
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+func (h *eventHub) subscribe(ctx context.Context) <-chan Event {
+	sub := &eventSubscriber{
+		ch:  make(chan Event, eventBufferSize),
+		ctx: ctx,
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs, sub)
+		close(sub.ch)
+		h.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+// publish delivers event to all subscribers, blocking on full buffers until
+// the subscriber drains them, cancels its context or done is closed
+func (h *eventHub) publish(event Event, done <-chan struct{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.ch <- event:
+		case <-sub.ctx.Done():
+		case <-done:
+			return
+		}
+	}
+}
+
+// hasSubscribers reports whether anyone is listening, so decoding can be skipped otherwise
+func (h *eventHub) hasSubscribers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs) > 0
+}
+
+// privateSessions routes the events of sessions the client uses internally,
+// such as the temporary sessions of FetchHistory, to their owner instead of
+// Events subscribers
+type privateSessions struct {
+	mu   sync.RWMutex
+	subs map[string]*eventSubscriber
+}
+
+func newPrivateSessions() *privateSessions {
+	return &privateSessions{
+		subs: make(map[string]*eventSubscriber),
+	}
+}
+
+// subscribe marks session as private and returns the channel its events are
+// delivered to, until ctx is cancelled
+func (p *privateSessions) subscribe(ctx context.Context, session string) <-chan Event {
+	sub := &eventSubscriber{
+		ch:  make(chan Event, eventBufferSize),
+		ctx: ctx,
+	}
+
+	p.mu.Lock()
+	p.subs[session] = sub
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		delete(p.subs, session)
+		close(sub.ch)
+		p.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+// deliver hands event to the owner of a private session, blocking on a full
+// buffer until the owner drains it, cancels its context or done is closed
+func (p *privateSessions) deliver(session string, event Event, done <-chan struct{}) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	sub, ok := p.subs[session]
+	if !ok {
+		return
+	}
+	select {
+	case sub.ch <- event:
+	case <-sub.ctx.Done():
+	case <-done:
+	}
+}
+
+// isPrivate reports whether the events of session go to an internal owner
+func (p *privateSessions) isPrivate(session string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.subs[session]
+	return ok
+}
+
+// broadcast delivers event to the owners of all private sessions
+func (p *privateSessions) broadcast(event Event, done <-chan struct{}) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, sub := range p.subs {
+		select {
+		case sub.ch <- event:
+		case <-sub.ctx.Done():
+		case <-done:
+			return
+		}
+	}
+}
+
+// responseSession returns the session ID a response is addressed to
+func responseSession(response TVResponse) string {
+	if len(response.Params) > 0 {
+		if session, ok := response.Params[0].(string); ok {
+			return session
+		}
+	}
+	return ""
+}
+
+// Events returns a channel of typed events decoded from incoming messages.
+// Events are produced while ReadMessage is running; pass a nil channel to
+// ReadMessage when only typed events are needed. The returned channel is
+// closed once ctx is cancelled.
+func (c *Client) Events(ctx context.Context) <-chan Event {
+	return c.events.subscribe(ctx)
+}
@@ -0,0 +1,117 @@
+package tvwsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func decodeTestResponse(t *testing.T, raw string) TVResponse {
+	t.Helper()
+	var response TVResponse
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("invalid test payload: %v", err)
+	}
+	return response
+}
+
+func TestDecodeEvent(t *testing.T) {
+	t.Run("quote update", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"qsd","p":["qs_1",{"n":"NASDAQ:AAPL","s":"ok","v":{"lp":190.5}}]}`))
+		quote, ok := event.(QuoteUpdateEvent)
+		if !ok {
+			t.Fatalf("DecodeEvent() = %T, want QuoteUpdateEvent", event)
+		}
+		if quote.QuoteSessionID != "qs_1" || quote.Data.Values.LastPrice != 190.5 {
+			t.Errorf("unexpected quote event: %+v", quote.QuoteDataMessage)
+		}
+	})
+
+	t.Run("bar update", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"du","p":["cs_1",{"sds_1":{"s":[{"i":9,"v":[1700000000,1,2,0.5,1.5,100]}],"t":"s1"}}]}`))
+		bar, ok := event.(BarUpdateEvent)
+		if !ok {
+			t.Fatalf("DecodeEvent() = %T, want BarUpdateEvent", event)
+		}
+		if bar.ChartSessionID != "cs_1" || len(bar.Data.SDS1.S) != 1 || bar.Data.SDS1.S[0].I != 9 {
+			t.Errorf("unexpected bar event: %+v", bar.DuMessage)
+		}
+	})
+
+	t.Run("series completed keeps timestamps", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"series_completed","p":["cs_1","sds_1","streaming","s1",{"rt_update_period":1}],"t":1736302609,"t_ms":1736302609050}`))
+		completed, ok := event.(SeriesCompletedEvent)
+		if !ok {
+			t.Fatalf("DecodeEvent() = %T, want SeriesCompletedEvent", event)
+		}
+		if completed.Time != 1736302609 || completed.TimeMS != 1736302609050 || completed.Config.RTUpdatePeriod != 1 {
+			t.Errorf("unexpected series completed event: %+v", completed.SeriesCompletedMessage)
+		}
+	})
+
+	t.Run("protocol error", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"symbol_error","p":["cs_1","sds_sym_1","invalid symbol"]}`))
+		protoErr, ok := event.(ProtocolErrorEvent)
+		if !ok {
+			t.Fatalf("DecodeEvent() = %T, want ProtocolErrorEvent", event)
+		}
+		var tvErr *TradingViewError
+		if protoErr.SessionID != "cs_1" || !errors.As(protoErr.Err, &tvErr) || tvErr.Code != ErrCodeSymbol || tvErr.Message != "invalid symbol" {
+			t.Errorf("unexpected protocol error event: %+v", protoErr)
+		}
+	})
+
+	t.Run("parse failure", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"qsd","p":["qs_1"]}`))
+		parseErr, ok := event.(ParseErrorEvent)
+		if !ok {
+			t.Fatalf("DecodeEvent() = %T, want ParseErrorEvent", event)
+		}
+		if parseErr.Response.Method != MethodQuoteData || parseErr.Err == nil {
+			t.Errorf("unexpected parse error event: %+v", parseErr)
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		event := DecodeEvent(decodeTestResponse(t, `{"m":"tickmark_update","p":["cs_1"]}`))
+		if _, ok := event.(UnknownEvent); !ok {
+			t.Fatalf("DecodeEvent() = %T, want UnknownEvent", event)
+		}
+	})
+}
+
+func TestEventHub(t *testing.T) {
+	hub := newEventHub()
+	done := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := hub.subscribe(ctx)
+	if !hub.hasSubscribers() {
+		t.Fatal("hub should have a subscriber")
+	}
+
+	hub.publish(UnknownEvent{Response: TVResponse{Method: "test"}}, done)
+	select {
+	case event := <-events:
+		if u, ok := event.(UnknownEvent); !ok || u.Response.Method != "test" {
+			t.Errorf("received %#v, want the published event", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("channel should be closed after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed after cancellation")
+	}
+	if hub.hasSubscribers() {
+		t.Error("hub should drop cancelled subscribers")
+	}
+}
@@ -0,0 +1,136 @@
+package tvwsclient
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	frameSeparator  = "~m~"
+	heartbeatPrefix = "~h~"
+)
+
+// PacketKind describes the content of a single packet inside a frame
+type PacketKind int
+
+const (
+	PacketJSON        PacketKind = iota // JSON payload, usually a {"m":...,"p":[...]} message
+	PacketHeartbeat                     // ~h~N heartbeat that must be echoed back
+	PacketSessionInfo                   // plain-text payload such as session info
+)
+
+func (k PacketKind) String() string {
+	switch k {
+	case PacketJSON:
+		return "json"
+	case PacketHeartbeat:
+		return "heartbeat"
+	case PacketSessionInfo:
+		return "session_info"
+	}
+	return "unknown"
+}
+
+// Packet is one length-prefixed payload of a ~m~len~m~payload frame
+type Packet struct {
+	Kind    PacketKind
+	Payload []byte // slice of the decoded frame, copy it before retaining the frame buffer
+}
+
+// DecodeFrame splits a raw WebSocket frame into its packets, honouring the
+// declared payload lengths so payloads may safely contain the ~m~ separator.
+//
+// Lengths are interpreted as bytes; when that does not land on a packet
+// boundary the length is retried as UTF-16 code units, which is how the
+// server counts non-ASCII payloads.
+func DecodeFrame(frame []byte) ([]Packet, error) {
+	if len(frame) == 0 {
+		return nil, WrapMessageError("decode_frame", fmt.Errorf("%w: empty frame", ErrInvalidMessage))
+	}
+
+	var packets []Packet
+	pos := 0
+	for pos < len(frame) {
+		if !bytes.HasPrefix(frame[pos:], []byte(frameSeparator)) {
+			return nil, WrapMessageError("decode_frame",
+				fmt.Errorf("%w: missing packet separator at offset %d", ErrInvalidMessage, pos))
+		}
+		pos += len(frameSeparator)
+
+		end := bytes.Index(frame[pos:], []byte(frameSeparator))
+		if end <= 0 {
+			return nil, WrapMessageError("decode_frame",
+				fmt.Errorf("%w: missing packet length at offset %d", ErrInvalidMessage, pos))
+		}
+		length, err := strconv.Atoi(string(frame[pos : pos+end]))
+		if err != nil || length < 0 {
+			return nil, WrapMessageError("decode_frame",
+				fmt.Errorf("%w: invalid packet length %q", ErrInvalidMessage, frame[pos:pos+end]))
+		}
+		pos += end + len(frameSeparator)
+
+		size, ok := payloadSize(frame[pos:], length)
+		if !ok {
+			return nil, WrapMessageError("decode_frame",
+				fmt.Errorf("%w: packet length %d does not match the %d bytes remaining", ErrInvalidMessage, length, len(frame)-pos))
+		}
+
+		payload := frame[pos : pos+size]
+		packets = append(packets, Packet{Kind: packetKind(payload), Payload: payload})
+		pos += size
+	}
+	return packets, nil
+}
+
+// payloadSize returns the number of bytes covered by a declared packet length
+func payloadSize(rest []byte, length int) (int, bool) {
+	if length <= len(rest) && atPacketBoundary(rest[length:]) {
+		return length, true
+	}
+
+	// Fall back to counting UTF-16 code units
+	units, size := 0, 0
+	for units < length && size < len(rest) {
+		r, n := utf8.DecodeRune(rest[size:])
+		units += utf16.RuneLen(r)
+		if units > length {
+			return 0, false
+		}
+		size += n
+	}
+	if units != length || !atPacketBoundary(rest[size:]) {
+		return 0, false
+	}
+	return size, true
+}
+
+func atPacketBoundary(rest []byte) bool {
+	return len(rest) == 0 || bytes.HasPrefix(rest, []byte(frameSeparator))
+}
+
+func packetKind(payload []byte) PacketKind {
+	switch {
+	case bytes.HasPrefix(payload, []byte(heartbeatPrefix)):
+		return PacketHeartbeat
+	case len(payload) > 0 && payload[0] == '{':
+		return PacketJSON
+	default:
+		return PacketSessionInfo
+	}
+}
+
+// EncodeFrame wraps each payload in the ~m~len~m~payload envelope and joins them into one frame
+func EncodeFrame(payloads ...string) string {
+	var b strings.Builder
+	for _, payload := range payloads {
+		b.WriteString(frameSeparator)
+		b.WriteString(strconv.Itoa(len(payload)))
+		b.WriteString(frameSeparator)
+		b.WriteString(payload)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package tvwsclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		payloads []string
+		kinds    []PacketKind
+	}{
+		{
+			name:     "single json packet",
+			frame:    `~m~24~m~{"m":"qsd","p":["qs_1"]}`,
+			payloads: []string{`{"m":"qsd","p":["qs_1"]}`},
+			kinds:    []PacketKind{PacketJSON},
+		},
+		{
+			name:     "heartbeat",
+			frame:    `~m~4~m~~h~1`,
+			payloads: []string{`~h~1`},
+			kinds:    []PacketKind{PacketHeartbeat},
+		},
+		{
+			name:     "multiple packets",
+			frame:    `~m~10~m~{"m":"du"}~m~5~m~~h~42~m~5~m~hello`,
+			payloads: []string{`{"m":"du"}`, `~h~42`, `hello`},
+			kinds:    []PacketKind{PacketJSON, PacketHeartbeat, PacketSessionInfo},
+		},
+		{
+			name:     "payload containing separator",
+			frame:    `~m~29~m~{"description":"A ~m~ B Co."}`,
+			payloads: []string{`{"description":"A ~m~ B Co."}`},
+			kinds:    []PacketKind{PacketJSON},
+		},
+		{
+			name:     "non-ascii payload counted in utf-16 units",
+			frame:    `~m~17~m~{"d":"Société ✓"}~m~4~m~~h~2`,
+			payloads: []string{`{"d":"Société ✓"}`, `~h~2`},
+			kinds:    []PacketKind{PacketJSON, PacketHeartbeat},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets, err := DecodeFrame([]byte(tt.frame))
+			if err != nil {
+				t.Fatalf("DecodeFrame() error = %v", err)
+			}
+			if len(packets) != len(tt.payloads) {
+				t.Fatalf("DecodeFrame() returned %d packets, want %d", len(packets), len(tt.payloads))
+			}
+			for i, packet := range packets {
+				if string(packet.Payload) != tt.payloads[i] {
+					t.Errorf("packet %d payload = %q, want %q", i, packet.Payload, tt.payloads[i])
+				}
+				if packet.Kind != tt.kinds[i] {
+					t.Errorf("packet %d kind = %v, want %v", i, packet.Kind, tt.kinds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFrameMalformed(t *testing.T) {
+	frames := map[string]string{
+		"empty frame":       ``,
+		"missing separator": `{"m":"qsd"}`,
+		"missing length":    `~m~~m~{}`,
+		"invalid length":    `~m~abc~m~{}`,
+		"length too long":   `~m~10~m~{}`,
+		"length too short":  `~m~1~m~{}`,
+		"trailing garbage":  `~m~2~m~{}xyz`,
+	}
+
+	for name, frame := range frames {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeFrame([]byte(frame))
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("DecodeFrame() error = %v, want ErrInvalidMessage", err)
+			}
+		})
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	payloads := []string{`{"m":"set_locale","p":["en","US"]}`, `~h~7`}
+	frame := EncodeFrame(payloads...)
+
+	packets, err := DecodeFrame([]byte(frame))
+	if err != nil {
+		t.Fatalf("DecodeFrame(EncodeFrame()) error = %v", err)
+	}
+	if len(packets) != len(payloads) {
+		t.Fatalf("got %d packets, want %d", len(packets), len(payloads))
+	}
+	for i, packet := range packets {
+		if string(packet.Payload) != payloads[i] {
+			t.Errorf("packet %d payload = %q, want %q", i, packet.Payload, payloads[i])
+		}
+	}
+}
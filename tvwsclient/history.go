@@ -0,0 +1,169 @@
+package tvwsclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// historyPageSize is the number of bars requested per create_series and
+// request_more_data message
+const historyPageSize = 1000
+
+// FetchHistory downloads the bars of symbol between from and to (inclusive).
+// It creates a temporary chart session requesting the r,from:to range, pages
+// back with request_more_data only while the server's answer does not reach
+// from, and deletes the session before returning. The bars are de-duplicated
+// and sorted by time. The session's messages are not passed to ReadMessage
+// channels, Events subscribers or the bar series.
+//
+// Responses are read by ReadMessage or Run, which must be running.
+func (c *Client) FetchHistory(ctx context.Context, symbol string, interval Interval, from, to time.Time) ([]CandleData, error) {
+	if symbol == "" {
+		return nil, WrapValidationError("fetch_history", "symbol must not be empty", ErrInvalidSymbol)
+	}
+	if err := interval.Validate(nil); err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, WrapValidationError("fetch_history", fmt.Sprintf("range end %v is before start %v", to, from), nil)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session := GenerateSession("cs_")
+	events := c.privateSessions.subscribe(ctx, session)
+	series := newChartSeries(1, symbol, interval.String(), historyPageSize)
+	// Ranges without a duration fall back to paging back from the latest bar
+	if rng := TimeRange(from, to); rng.validate("fetch_history") == nil {
+		series.setRange(rng)
+	}
+	defer func() {
+		if err := c.sendRequest(NewRequest("chart_delete_session", session, "")); err != nil {
+			c.logger.Debug("failed to delete history session", "session", session, "error", err)
+		}
+	}()
+	if err := sendHistorySession(c, session, series); err != nil {
+		return nil, err
+	}
+
+	bars := make(map[int64]DuSeriesData)
+	var barCloseTime int64
+	oldest := int64(-1)
+	for {
+		var event Event
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil, ctx.Err()
+			}
+			event = e
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.done:
+			return nil, WrapConnectionError("fetch_history", ErrConnectionClosed)
+		}
+
+		switch e := event.(type) {
+		case TimescaleUpdateEvent:
+			if e.ChartSessionID != session {
+				continue
+			}
+			data := e.Data.Series[series.ID]
+			for _, bar := range data.S {
+				if len(bar.V) > barClose {
+					bars[int64(bar.V[barTimestamp])] = bar
+				}
+			}
+			if data.Lbs.BarCloseTime > barCloseTime {
+				barCloseTime = data.Lbs.BarCloseTime
+			}
+
+		case SeriesCompletedEvent:
+			if e.ChartSessionID != session {
+				continue
+			}
+			previous := oldest
+			oldest = oldestBarTime(bars)
+			// Stop once from is covered or a page brought no older bars
+			if len(bars) == 0 || oldest <= from.Unix() || oldest == previous {
+				return historyCandles(symbol, series.Interval, bars, barCloseTime, from, to), nil
+			}
+			if err := c.sendRequest(NewRequest("request_more_data", session, series.ID, historyPageSize)); err != nil {
+				return nil, err
+			}
+
+		case ProtocolErrorEvent:
+			if e.SessionID == session {
+				return nil, e.Err
+			}
+
+		case SubscriptionsRestoredEvent:
+			// The temporary session did not survive the reconnect
+			return nil, WrapSessionError("fetch_history", fmt.Errorf("%w: connection lost while fetching", ErrSessionNotFound))
+		}
+	}
+}
+
+// sendHistorySession creates an unregistered chart session streaming series
+func sendHistorySession(c *Client, session string, series ChartSeries) error {
+	if err := SendChartCreateSessionMessage(c, session); err != nil {
+		return err
+	}
+	if err := SendSwitchTimezoneMessage(c, session); err != nil {
+		return err
+	}
+	if err := sendResolveSymbol(c, session, series.SymbolID, series.Symbol); err != nil {
+		return err
+	}
+	return sendCreateSeries(c, session, series)
+}
+
+func oldestBarTime(bars map[int64]DuSeriesData) int64 {
+	oldest := int64(-1)
+	for t := range bars {
+		if oldest < 0 || t < oldest {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// historyCandles returns the bars inside [from, to] sorted by time
+func historyCandles(symbol, interval string, bars map[int64]DuSeriesData, barCloseTime int64, from, to time.Time) []CandleData {
+	data := SeriesData{S: make([]DuSeriesData, 0, len(bars))}
+	data.Lbs.BarCloseTime = barCloseTime
+	for _, bar := range bars {
+		data.S = append(data.S, bar)
+	}
+
+	// Finality depends on the newest bar, so filter after converting
+	candles := seriesCandles(newCandleMeta(symbol, interval), data, time.Now())
+	inRange := candles[:0]
+	for _, candle := range candles {
+		if candle.Timestamp >= from.Unix() && candle.Timestamp <= to.Unix() {
+			inRange = append(inRange, candle)
+		}
+	}
+	return inRange
+}
+
+// splitSymbol splits "EXCHANGE:TICKER", or the symbol of a ={...} descriptor,
+// into its exchange and ticker
+func splitSymbol(symbol string) (string, string) {
+	if strings.HasPrefix(symbol, "=") {
+		var descriptor struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := json.Unmarshal([]byte(symbol[1:]), &descriptor); err == nil {
+			symbol = descriptor.Symbol
+		}
+	}
+	if exchange, ticker, ok := strings.Cut(symbol, ":"); ok {
+		return exchange, ticker
+	}
+	return "", symbol
+}
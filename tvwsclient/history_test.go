@@ -0,0 +1,151 @@
+package tvwsclient
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestFetchHistoryPagesBack(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := tvwstest.GenerateBars(start, time.Minute, 2500)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars), tvwstest.WithMaxBars(1000))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	go client.ReadMessage(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	from, to := time.Unix(bars[200].Time, 0), time.Unix(bars[2399].Time, 0)
+	candles, err := client.FetchHistory(ctx, testSymbol, Minutes(1), from, to)
+	if err != nil {
+		t.Fatalf("FetchHistory() error = %v", err)
+	}
+	if len(candles) != 2200 {
+		t.Fatalf("FetchHistory() returned %d bars, want 2200", len(candles))
+	}
+	for i, candle := range candles {
+		want := bars[200+i]
+		if candle.Timestamp != want.Time || candle.Close != want.Close || candle.Volume != want.Volume {
+			t.Fatalf("bar %d = %+v, want %+v", i, candle, want)
+		}
+	}
+	if c := candles[0]; c.Exchange != "BINANCE" || c.Symbol != "BTCUSDT" || c.Timeframe != "1" {
+		t.Errorf("candle metadata = %s/%s/%s", c.Exchange, c.Symbol, c.Timeframe)
+	}
+
+	created, err := srv.WaitForMessages(ctx, "create_series", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := created[0].ParamString(6), fmt.Sprintf("r,%d:%d", from.Unix(), to.Unix()); got != want {
+		t.Errorf("create_series range = %q, want %q", got, want)
+	}
+	if got := len(srv.Messages("request_more_data")); got != 2 {
+		t.Errorf("sent %d request_more_data messages, want 2", got)
+	}
+	deleted, err := srv.WaitForMessages(ctx, "chart_delete_session", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created := srv.Messages("chart_create_session"); deleted[0].ParamString(0) != created[0].ParamString(0) {
+		t.Errorf("deleted session %s, created %s", deleted[0].ParamString(0), created[0].ParamString(0))
+	}
+	if len(client.Subscriptions()) != 0 {
+		t.Error("history session was registered for restore")
+	}
+}
+
+func TestFetchHistoryRequestsRange(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := tvwstest.GenerateBars(start, time.Minute, 5000)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars), tvwstest.WithMaxBars(1000))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	responses := make(chan TVResponse, 64)
+	go client.ReadMessage(responses)
+
+	// An old, narrow range is served by the first snapshot
+	candles, err := client.FetchHistory(ctx, testSymbol, Minutes(1), time.Unix(bars[100].Time, 0), time.Unix(bars[149].Time, 0))
+	if err != nil {
+		t.Fatalf("FetchHistory() error = %v", err)
+	}
+	if len(candles) != 50 || candles[0].Timestamp != bars[100].Time {
+		t.Fatalf("FetchHistory() returned %d bars starting at %d", len(candles), candles[0].Timestamp)
+	}
+	if got := len(srv.Messages("request_more_data")); got != 0 {
+		t.Errorf("sent %d request_more_data messages, want 0", got)
+	}
+
+	// The temporary session's traffic stays internal
+	session := srv.Messages("chart_create_session")[0].ParamString(0)
+	if _, ok := client.BarSeries(session, "sds_1"); ok {
+		t.Error("history session was added to the bar series")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Events() received %T from the history session", event)
+	case response := <-responses:
+		t.Errorf("ReadMessage() forwarded %s from the history session", response.Method)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFetchHistoryStopsAtOldestBar(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := tvwstest.GenerateBars(start, time.Hour, 50)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, bars))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	go client.ReadMessage(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	candles, err := client.FetchHistory(ctx, testSymbol, Hours(1), start.AddDate(-1, 0, 0), start.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("FetchHistory() error = %v", err)
+	}
+	if len(candles) != len(bars) {
+		t.Errorf("FetchHistory() returned %d bars, want %d", len(candles), len(bars))
+	}
+}
+
+func TestFetchHistoryErrors(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+	srv.RejectSymbol("NASDAQ:NOPE")
+
+	client := newTestClient(t, srv)
+	go client.ReadMessage(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	now := time.Now()
+
+	if _, err := client.FetchHistory(ctx, "NASDAQ:NOPE", Days(1), now.AddDate(0, -1, 0), now); err == nil {
+		t.Error("FetchHistory() of a rejected symbol succeeded")
+	}
+	if _, err := client.FetchHistory(ctx, testSymbol, Days(1), now, now.AddDate(0, -1, 0)); err == nil {
+		t.Error("FetchHistory() accepted an inverted range")
+	}
+	if _, err := client.FetchHistory(ctx, testSymbol, Interval{}, now.AddDate(0, -1, 0), now); err == nil {
+		t.Error("FetchHistory() accepted an invalid interval")
+	}
+
+	cancelled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	if _, err := client.FetchHistory(cancelled, testSymbol, Days(1), now.AddDate(0, -1, 0), now); err != context.Canceled {
+		t.Errorf("FetchHistory() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
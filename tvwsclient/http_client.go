@@ -1,23 +1,83 @@
 package tvwsclient
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 )
 
+// ErrServerError is returned when TradingView answers with a 5xx status
+var ErrServerError = errors.New("tradingview server error")
+
+// TVHttpClient performs TradingView REST calls. All requests go through
+// DoRequest, so transports and middleware apply to every endpoint.
 type TVHttpClient struct {
 	baseURL     string
+	searchURL   string
 	deviceToken string
 	sessionID   string
 	sessionSign string
 	httpClient  *http.Client
+	transport   http.RoundTripper
+	middleware  []HTTPMiddleware
+}
+
+var _ HTTPClient = (*TVHttpClient)(nil)
+
+// HTTPClientOption configures a TVHttpClient
+type HTTPClientOption func(*TVHttpClient)
+
+// HTTPMiddleware wraps the transport used by TVHttpClient, e.g. for logging or retries
+type HTTPMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper, handy for stubbing responses in tests
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithHTTPClient sets the *http.Client used for requests, e.g. to configure a proxy
+func WithHTTPClient(httpClient *http.Client) HTTPClientOption {
+	return func(c *TVHttpClient) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
 }
 
-func NewTVHttpClient(baseURL string, deviceToken string, sessionID string, sessionSign string) *TVHttpClient {
-	return &TVHttpClient{
+// WithTransport sets the transport requests are sent through
+func WithTransport(transport http.RoundTripper) HTTPClientOption {
+	return func(c *TVHttpClient) {
+		c.transport = transport
+	}
+}
+
+// WithMiddleware wraps the transport with middleware; the first one is the outermost
+func WithMiddleware(middleware ...HTTPMiddleware) HTTPClientOption {
+	return func(c *TVHttpClient) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// WithSymbolSearchURL overrides the symbol search endpoint base URL
+func WithSymbolSearchURL(searchURL string) HTTPClientOption {
+	return func(c *TVHttpClient) {
+		c.searchURL = searchURL
+	}
+}
+
+func NewTVHttpClient(baseURL string, deviceToken string, sessionID string, sessionSign string, opts ...HTTPClientOption) *TVHttpClient {
+	c := &TVHttpClient{
 		baseURL:     baseURL,
+		searchURL:   "https://symbol-search.tradingview.com",
 		deviceToken: deviceToken,
 		sessionID:   sessionID,
 		sessionSign: sessionSign,
@@ -25,13 +85,46 @@ func NewTVHttpClient(baseURL string, deviceToken string, sessionID string, sessi
 			Timeout: 30 * time.Second,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Build the transport chain on a copy so a caller-supplied *http.Client is not modified
+	if c.transport != nil || len(c.middleware) > 0 {
+		transport := c.transport
+		if transport == nil {
+			transport = c.httpClient.Transport
+		}
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		for i := len(c.middleware) - 1; i >= 0; i-- {
+			transport = c.middleware[i](transport)
+		}
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+	}
+	return c
 }
 
-func (c *TVHttpClient) GetQuoteToken() (string, error) {
+// DoRequest implements HTTPClient
+func (c *TVHttpClient) DoRequest(method, url string, body []byte) ([]byte, error) {
+	return c.DoRequestContext(context.Background(), method, url, body)
+}
+
+// DoRequestContext sends an authenticated request to TradingView and returns
+// the response body, converting non-2xx responses into typed errors
+func (c *TVHttpClient) DoRequestContext(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
 	// Create request
-	req, err := http.NewRequest("POST", c.baseURL+"/quote_token/", nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Set headers
@@ -49,24 +142,179 @@ func (c *TVHttpClient) GetQuoteToken() (string, error) {
 		"sessionid="+c.sessionID+"; "+
 		"sessionid_sign="+c.sessionSign)
 
-	// Add form data
-	req.URL.RawQuery = "grabSession=true"
-
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, WrapConnectionError("http_request", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, WrapConnectionError("http_request", err)
+	}
+
+	if err := checkResponseStatus("http_request", resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+func (c *TVHttpClient) GetQuoteToken() (string, error) {
+	return c.GetQuoteTokenContext(context.Background())
+}
+
+// GetQuoteTokenContext requests a quote token, validating that the response is a JWT
+func (c *TVHttpClient) GetQuoteTokenContext(ctx context.Context) (string, error) {
+	body, err := c.DoRequestContext(ctx, http.MethodPost, c.baseURL+"/quote_token/?grabSession=true", nil)
 	if err != nil {
 		return "", err
 	}
+	return parseQuoteToken(body)
+}
+
+// checkResponseStatus converts non-2xx responses into typed errors
+func checkResponseStatus(op string, resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	message := fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	if reason := errorEnvelopeMessage(body); reason != "" {
+		message += ": " + reason
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return NewTradingViewError(op, ErrCodeAuth, message, ErrAuthenticationFailed)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return NewTradingViewError(op, ErrCodeRateLimit, message, ErrRateLimitExceeded)
+	case resp.StatusCode >= 500:
+		return NewTradingViewError(op, ErrCodeConnection, message, ErrServerError)
+	default:
+		return NewTradingViewError(op, ErrCodeInternal, message, nil)
+	}
+}
+
+// parseQuoteToken extracts the JWT from a quote token response body
+func parseQuoteToken(body []byte) (string, error) {
+	trimmed := strings.TrimSpace(string(body))
+
+	// JSON error envelopes, e.g. {"error":"..."} or {"s":"error","errmsg":"..."}
+	if strings.HasPrefix(trimmed, "{") {
+		message := errorEnvelopeMessage(body)
+		if message == "" {
+			message = "unexpected JSON response"
+		}
+		return "", NewTradingViewError("get_quote_token", ErrCodeAuth, message, ErrAuthenticationFailed)
+	}
 
 	// Remove quotes from the response if present
-	token := strings.Trim(string(body), "\"")
+	token := strings.Trim(trimmed, "\"")
 
+	if !looksLikeJWT(token) {
+		return "", NewTradingViewError("get_quote_token", ErrCodeAuth,
+			"response is not a quote token, check the session cookies", ErrAuthenticationFailed)
+	}
 	return token, nil
 }
+
+// errorEnvelopeMessage returns the error message of a JSON error body, if any
+func errorEnvelopeMessage(body []byte) string {
+	var envelope struct {
+		Error   string `json:"error"`
+		ErrMsg  string `json:"errmsg"`
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return ""
+	}
+	for _, m := range []string{envelope.Error, envelope.ErrMsg, envelope.Message, envelope.Detail} {
+		if m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// looksLikeJWT reports whether token has three non-empty base64url segments
+func looksLikeJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_=") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// LoggingMiddleware logs every request with its status and duration
+func LoggingMiddleware(logger *slog.Logger) HTTPMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logger.Error("http request failed",
+					"method", req.Method,
+					"url", req.URL.Redacted(),
+					"duration", time.Since(start),
+					"error", err)
+				return nil, err
+			}
+			logger.Debug("http request",
+				"method", req.Method,
+				"url", req.URL.Redacted(),
+				"status", resp.StatusCode,
+				"duration", time.Since(start))
+			return resp, nil
+		})
+	}
+}
+
+// RetryMiddleware retries requests that fail at the transport level or with a
+// 429/5xx status, waiting delay, doubled after every attempt, in between
+func RetryMiddleware(attempts int, delay time.Duration) HTTPMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			wait := delay
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+				if !retryable || attempt >= attempts {
+					return resp, err
+				}
+
+				// Requests with a body can only be replayed when it can be recreated
+				if req.Body != nil && req.GetBody == nil {
+					return resp, err
+				}
+				if resp != nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+				wait *= 2
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+			}
+		})
+	}
+}
@@ -44,6 +44,7 @@ type MessageSender interface {
 	
 	// Quote messages
 	SendQuoteCreateSessionMessage(session string) error
+	SendQuoteDeleteSessionMessage(session string) error
 	SendQuoteSetFieldsMessage(session string) error
 	SendQuoteRemoveSymbolsMessage(session string, symbols []string) error
 }
@@ -115,7 +116,8 @@ type CandleData struct {
 	High      float64
 	Low       float64
 	Close     float64
-	Volume    float64
+	Volume    float64 // 0 when the series has no volume
+	Final     bool    // false while the bar is still forming
 }
 
 type CandleFilters struct {
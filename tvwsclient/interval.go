@@ -0,0 +1,288 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalUnit is the unit of a chart resolution
+type IntervalUnit int
+
+const (
+	UnitSeconds IntervalUnit = iota + 1
+	UnitMinutes
+	UnitHours
+	UnitDays
+	UnitWeeks
+	UnitMonths
+	UnitRange // range bars, a new bar starts after the price moved by Multiplier ticks
+	UnitTicks // tick bars, a new bar starts every Multiplier trades
+)
+
+// String returns the name of the unit
+func (u IntervalUnit) String() string {
+	switch u {
+	case UnitSeconds:
+		return "seconds"
+	case UnitMinutes:
+		return "minutes"
+	case UnitHours:
+		return "hours"
+	case UnitDays:
+		return "days"
+	case UnitWeeks:
+		return "weeks"
+	case UnitMonths:
+		return "months"
+	case UnitRange:
+		return "range"
+	case UnitTicks:
+		return "ticks"
+	default:
+		return fmt.Sprintf("IntervalUnit(%d)", int(u))
+	}
+}
+
+// Interval is a chart resolution such as 15 seconds, 4 hours or 1 month
+type Interval struct {
+	Multiplier int
+	Unit       IntervalUnit
+}
+
+// Constructors for intervals of n units
+func Seconds(n int) Interval   { return Interval{n, UnitSeconds} }
+func Minutes(n int) Interval   { return Interval{n, UnitMinutes} }
+func Hours(n int) Interval     { return Interval{n, UnitHours} }
+func Days(n int) Interval      { return Interval{n, UnitDays} }
+func Weeks(n int) Interval     { return Interval{n, UnitWeeks} }
+func Months(n int) Interval    { return Interval{n, UnitMonths} }
+func RangeBars(n int) Interval { return Interval{n, UnitRange} }
+func TickBars(n int) Interval  { return Interval{n, UnitTicks} }
+
+// ParseInterval parses a TradingView resolution: "15S", "5", "1H", "240",
+// "D", "1D", "2W", "M", "3M", "10R" or "100T". Bare numbers are minutes.
+// Suffixes are upper case; only "h" is also accepted, while "1m" is rejected
+// because it could mean a minute or a month.
+func ParseInterval(s string) (Interval, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return Interval{}, WrapValidationError("parse_interval", "interval must not be empty", nil)
+	}
+
+	unit := UnitMinutes
+	digits := value
+	switch value[len(value)-1] {
+	case 'm':
+		return Interval{}, WrapValidationError("parse_interval",
+			fmt.Sprintf("ambiguous interval %q, use M for months or a bare number for minutes", s), nil)
+	case 'S':
+		unit = UnitSeconds
+	case 'H', 'h':
+		unit = UnitHours
+	case 'D':
+		unit = UnitDays
+	case 'W':
+		unit = UnitWeeks
+	case 'M':
+		unit = UnitMonths
+	case 'R':
+		unit = UnitRange
+	case 'T':
+		unit = UnitTicks
+	}
+	if unit != UnitMinutes {
+		digits = value[:len(value)-1]
+	}
+
+	multiplier := 1
+	if digits != "" || unit == UnitMinutes {
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 1 || strings.HasPrefix(digits, "+") {
+			return Interval{}, WrapValidationError("parse_interval", fmt.Sprintf("invalid interval %q", s), err)
+		}
+		multiplier = n
+	}
+	return Interval{Multiplier: multiplier, Unit: unit}, nil
+}
+
+// String returns the resolution in the form create_series expects. Hours
+// are sent as minutes, so Hours(4) is "240".
+func (i Interval) String() string {
+	n := strconv.Itoa(i.Multiplier)
+	switch i.Unit {
+	case UnitSeconds:
+		return n + "S"
+	case UnitHours:
+		return strconv.Itoa(i.Multiplier * 60)
+	case UnitDays:
+		return n + "D"
+	case UnitWeeks:
+		return n + "W"
+	case UnitMonths:
+		return n + "M"
+	case UnitRange:
+		return n + "R"
+	case UnitTicks:
+		return n + "T"
+	default:
+		return n
+	}
+}
+
+// normalizeInterval checks a resolution given as a string and returns it in
+// the form create_series expects
+func normalizeInterval(s string) (string, error) {
+	interval, err := ParseInterval(s)
+	if err != nil {
+		return "", err
+	}
+	return interval.String(), nil
+}
+
+// IsIntraday reports whether bars are shorter than a day, including range
+// and tick bars
+func (i Interval) IsIntraday() bool {
+	switch i.Unit {
+	case UnitDays, UnitWeeks, UnitMonths:
+		return false
+	}
+	return true
+}
+
+// IsTimeBased reports whether bars cover fixed calendar periods, which is
+// false for range and tick bars
+func (i Interval) IsTimeBased() bool {
+	return i.Unit != UnitRange && i.Unit != UnitTicks
+}
+
+// Duration returns the length of a bar. It reports false for months and for
+// range and tick bars, whose length varies.
+func (i Interval) Duration() (time.Duration, bool) {
+	n := time.Duration(i.Multiplier)
+	switch i.Unit {
+	case UnitSeconds:
+		return n * time.Second, true
+	case UnitMinutes:
+		return n * time.Minute, true
+	case UnitHours:
+		return n * time.Hour, true
+	case UnitDays:
+		return n * 24 * time.Hour, true
+	case UnitWeeks:
+		return n * 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// Validate checks that the interval is well-formed and, when info is not
+// nil, that the symbol offers it
+func (i Interval) Validate(info *SymbolInfo) error {
+	if i.Multiplier < 1 || i.Unit < UnitSeconds || i.Unit > UnitTicks {
+		return WrapValidationError("interval.validate", fmt.Sprintf("invalid interval %d %s", i.Multiplier, i.Unit), nil)
+	}
+	if info == nil {
+		return nil
+	}
+	if i.Unit == UnitTicks && !info.IsTickbarsAvailable {
+		return WrapValidationError("interval.validate", fmt.Sprintf("%s has no tick bars", info.ProName), nil)
+	}
+	if i.IsIntraday() && !info.HasIntraday {
+		return WrapValidationError("interval.validate", fmt.Sprintf("%s has no intraday data for %s", info.ProName, i), nil)
+	}
+	return nil
+}
+
+// epochMonday is the first Monday of the Unix epoch, where week bars are anchored
+var epochMonday = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// BarStart returns the start of the bar containing t in t's location.
+// Intraday bars are aligned to local midnight on the wall clock, so 4 hour
+// bars start at 00:00, 04:00, ... even on DST transition days, where the bar
+// spanning the change is shorter or longer and a repeated hour forms bars of
+// its own. Days are aligned to midnight,
+// weeks to Monday and months to the first of the month; multiples are counted
+// from the Unix epoch. Range and tick bars have no fixed boundaries and t is
+// returned.
+func (i Interval) BarStart(t time.Time) time.Time {
+	if i.Multiplier < 1 {
+		return t
+	}
+	loc := t.Location()
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	switch i.Unit {
+	case UnitSeconds, UnitMinutes, UnitHours:
+		elapsed := clockSeconds(t)
+		offset := elapsed % i.seconds()
+		// Going back keeps t's UTC offset, which tells the repeated hour apart
+		// when clocks fall back; bars starting before a transition are rebuilt
+		// from the wall clock
+		start := t.Add(-time.Duration(offset)*time.Second - time.Duration(t.Nanosecond()))
+		if clockSeconds(start) == elapsed-offset {
+			return start
+		}
+		return time.Date(year, month, day, 0, 0, elapsed-offset, 0, loc)
+	case UnitDays:
+		days := civilDays(year, month, day)
+		return midnight.AddDate(0, 0, -int(floorMod(days, int64(i.Multiplier))))
+	case UnitWeeks:
+		days := civilDays(year, month, day) - civilDays(epochMonday.Date())
+		return midnight.AddDate(0, 0, -int(floorMod(days, int64(7*i.Multiplier))))
+	case UnitMonths:
+		months := int64(year)*12 + int64(month-1)
+		start := months - floorMod(months, int64(i.Multiplier))
+		return time.Date(int(start/12), time.Month(start%12+1), 1, 0, 0, 0, 0, loc)
+	}
+	return t
+}
+
+// NextBarStart returns the start of the bar following the one containing t
+func (i Interval) NextBarStart(t time.Time) time.Time {
+	start := i.BarStart(t)
+	switch i.Unit {
+	case UnitSeconds, UnitMinutes, UnitHours:
+		size, _ := i.Duration()
+		next := start.Add(size)
+		if !i.BarStart(next).Equal(next) {
+			// A DST transition or midnight lies within the bar
+			year, month, day := start.Date()
+			next = i.BarStart(time.Date(year, month, day, 0, 0, clockSeconds(start)+i.seconds(), 0, start.Location()))
+		}
+		return next
+	case UnitDays:
+		return start.AddDate(0, 0, i.Multiplier)
+	case UnitWeeks:
+		return start.AddDate(0, 0, 7*i.Multiplier)
+	case UnitMonths:
+		return start.AddDate(0, i.Multiplier, 0)
+	}
+	return t
+}
+
+// seconds returns the length of an intraday bar in seconds
+func (i Interval) seconds() int {
+	size, _ := i.Duration()
+	return int(size / time.Second)
+}
+
+// clockSeconds returns the seconds since midnight shown on t's wall clock
+func clockSeconds(t time.Time) int {
+	hour, min, sec := t.Clock()
+	return hour*3600 + min*60 + sec
+}
+
+// civilDays returns the number of days between 1970-01-01 and the date
+func civilDays(year int, month time.Month, day int) int64 {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
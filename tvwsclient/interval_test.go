@@ -0,0 +1,199 @@
+package tvwsclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Interval
+		string string
+	}{
+		{"15S", Seconds(15), "15S"},
+		{"1", Minutes(1), "1"},
+		{"240", Minutes(240), "240"},
+		{"4h", Hours(4), "240"},
+		{"D", Days(1), "1D"},
+		{"1D", Days(1), "1D"},
+		{"2W", Weeks(2), "2W"},
+		{"M", Months(1), "1M"},
+		{"1M", Months(1), "1M"},
+		{"3M", Months(3), "3M"},
+		{"10R", RangeBars(10), "10R"},
+		{" 100T ", TickBars(100), "100T"},
+	}
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.in)
+		if err != nil {
+			t.Errorf("ParseInterval(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseInterval(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.string {
+			t.Errorf("ParseInterval(%q).String() = %q, want %q", tt.in, got.String(), tt.string)
+		}
+	}
+
+	for _, in := range []string{"", "0", "-5", "+5", "0D", "1.5", "X", "1X", "5MS", "1m", "m", "15s", "1d", "2w"} {
+		if _, err := ParseInterval(in); err == nil {
+			t.Errorf("ParseInterval(%q) succeeded, want error", in)
+		}
+	}
+
+	// A lower-case m would silently subscribe a monthly series
+	_, err := ParseInterval("1m")
+	var tvErr *TradingViewError
+	if !errors.As(err, &tvErr) || tvErr.Code != ErrCodeValidation {
+		t.Errorf("ParseInterval(\"1m\") error = %v, want %s", err, ErrCodeValidation)
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	tests := []struct {
+		interval Interval
+		want     time.Duration
+		ok       bool
+	}{
+		{Seconds(30), 30 * time.Second, true},
+		{Minutes(15), 15 * time.Minute, true},
+		{Hours(4), 4 * time.Hour, true},
+		{Days(1), 24 * time.Hour, true},
+		{Weeks(1), 7 * 24 * time.Hour, true},
+		{Months(1), 0, false},
+		{TickBars(100), 0, false},
+		{RangeBars(10), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.interval.Duration()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%v.Duration() = %v, %v, want %v, %v", tt.interval, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIntervalValidate(t *testing.T) {
+	daily := &SymbolInfo{ProName: "INDEX:SPX"}
+	intraday := &SymbolInfo{ProName: "NASDAQ:AAPL", HasIntraday: true}
+	ticks := &SymbolInfo{ProName: "BINANCE:BTCUSDT", HasIntraday: true, IsTickbarsAvailable: true}
+
+	tests := []struct {
+		interval Interval
+		info     *SymbolInfo
+		valid    bool
+	}{
+		{Days(1), daily, true},
+		{Minutes(5), daily, false},
+		{Minutes(5), intraday, true},
+		{TickBars(10), intraday, false},
+		{TickBars(10), ticks, true},
+		{RangeBars(10), daily, false},
+		{Minutes(0), nil, false},
+		{Interval{1, 0}, nil, false},
+		{Weeks(1), nil, true},
+	}
+	for _, tt := range tests {
+		err := tt.interval.Validate(tt.info)
+		if (err == nil) != tt.valid {
+			t.Errorf("%v.Validate(%v) error = %v, want valid %v", tt.interval, tt.info, err, tt.valid)
+		}
+	}
+}
+
+func TestIntervalBarBoundaries(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone database unavailable")
+	}
+	at := time.Date(2025, 3, 12, 14, 37, 42, 0, newYork) // a Wednesday
+
+	tests := []struct {
+		interval    Interval
+		start, next time.Time
+	}{
+		{Seconds(15), time.Date(2025, 3, 12, 14, 37, 30, 0, newYork), time.Date(2025, 3, 12, 14, 37, 45, 0, newYork)},
+		{Minutes(15), time.Date(2025, 3, 12, 14, 30, 0, 0, newYork), time.Date(2025, 3, 12, 14, 45, 0, 0, newYork)},
+		{Hours(4), time.Date(2025, 3, 12, 12, 0, 0, 0, newYork), time.Date(2025, 3, 12, 16, 0, 0, 0, newYork)},
+		{Days(1), time.Date(2025, 3, 12, 0, 0, 0, 0, newYork), time.Date(2025, 3, 13, 0, 0, 0, 0, newYork)},
+		{Weeks(1), time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), time.Date(2025, 3, 17, 0, 0, 0, 0, newYork)},
+		{Months(1), time.Date(2025, 3, 1, 0, 0, 0, 0, newYork), time.Date(2025, 4, 1, 0, 0, 0, 0, newYork)},
+		{Months(3), time.Date(2025, 1, 1, 0, 0, 0, 0, newYork), time.Date(2025, 4, 1, 0, 0, 0, 0, newYork)},
+		{TickBars(100), at, at},
+	}
+	for _, tt := range tests {
+		if got := tt.interval.BarStart(at); !got.Equal(tt.start) {
+			t.Errorf("%v.BarStart() = %v, want %v", tt.interval, got, tt.start)
+		}
+		if got := tt.interval.NextBarStart(at); !got.Equal(tt.next) {
+			t.Errorf("%v.NextBarStart() = %v, want %v", tt.interval, got, tt.next)
+		}
+	}
+
+	// A bar start is its own start
+	for _, interval := range []Interval{Minutes(5), Days(2), Weeks(2), Months(6)} {
+		start := interval.BarStart(at)
+		if got := interval.BarStart(start); !got.Equal(start) {
+			t.Errorf("%v.BarStart(%v) = %v, want itself", interval, start, got)
+		}
+	}
+
+	// Bars crossing midnight end there
+	late := time.Date(2025, 3, 12, 23, 58, 0, 0, newYork)
+	if got, want := Minutes(7).NextBarStart(late), time.Date(2025, 3, 13, 0, 0, 0, 0, newYork); !got.Equal(want) {
+		t.Errorf("7m NextBarStart(%v) = %v, want %v", late, got, want)
+	}
+}
+
+func TestIntervalBarBoundariesAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone database unavailable")
+	}
+	edt := time.FixedZone("EDT", -4*3600)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		name        string
+		interval    Interval
+		at          time.Time
+		start, next time.Time
+	}{
+		// Clocks jump from 02:00 EST to 03:00 EDT on 2025-03-09
+		{"spring 4h", Hours(4), time.Date(2025, 3, 9, 6, 30, 0, 0, edt),
+			time.Date(2025, 3, 9, 4, 0, 0, 0, edt), time.Date(2025, 3, 9, 8, 0, 0, 0, edt)},
+		{"spring 4h across the gap", Hours(4), time.Date(2025, 3, 9, 3, 30, 0, 0, edt),
+			time.Date(2025, 3, 9, 0, 0, 0, 0, est), time.Date(2025, 3, 9, 4, 0, 0, 0, edt)},
+		{"spring 90m", Minutes(90), time.Date(2025, 3, 9, 3, 10, 0, 0, edt),
+			time.Date(2025, 3, 9, 3, 0, 0, 0, edt), time.Date(2025, 3, 9, 4, 30, 0, 0, edt)},
+		// Clocks fall back from 02:00 EDT to 01:00 EST on 2025-11-02
+		{"fall 1h first 01:00", Hours(1), time.Date(2025, 11, 2, 1, 30, 0, 0, edt),
+			time.Date(2025, 11, 2, 1, 0, 0, 0, edt), time.Date(2025, 11, 2, 1, 0, 0, 0, est)},
+		{"fall 1h second 01:00", Hours(1), time.Date(2025, 11, 2, 1, 30, 0, 0, est),
+			time.Date(2025, 11, 2, 1, 0, 0, 0, est), time.Date(2025, 11, 2, 2, 0, 0, 0, est)},
+		{"fall 1m repeated hour", Minutes(1), time.Date(2025, 11, 2, 1, 30, 30, 0, est),
+			time.Date(2025, 11, 2, 1, 30, 0, 0, est), time.Date(2025, 11, 2, 1, 31, 0, 0, est)},
+		{"fall 4h", Hours(4), time.Date(2025, 11, 2, 9, 0, 0, 0, est),
+			time.Date(2025, 11, 2, 8, 0, 0, 0, est), time.Date(2025, 11, 2, 12, 0, 0, 0, est)},
+		{"fall 4h across the change", Hours(4), time.Date(2025, 11, 2, 1, 30, 0, 0, est),
+			time.Date(2025, 11, 2, 0, 0, 0, 0, edt), time.Date(2025, 11, 2, 4, 0, 0, 0, est)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := tt.at.In(newYork)
+			start := tt.interval.BarStart(at)
+			if !start.Equal(tt.start) {
+				t.Errorf("BarStart(%v) = %v, want %v", at, start, tt.start)
+			}
+			if got := tt.interval.BarStart(start); !got.Equal(start) {
+				t.Errorf("BarStart(%v) = %v, want itself", start, got)
+			}
+			if got := tt.interval.NextBarStart(at); !got.Equal(tt.next) {
+				t.Errorf("NextBarStart(%v) = %v, want %v", at, got, tt.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package tvwsclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Endpoint is a TradingView WebSocket data host
+type Endpoint string
+
+const (
+	EndpointProData    Endpoint = "prodata"    // real-time data for logged-in users
+	EndpointData       Endpoint = "data"       // delayed data
+	EndpointWidgetData Endpoint = "widgetdata" // data used by embedded widgets
+)
+
+// URL returns the WebSocket URL of the endpoint
+func (e Endpoint) URL() string {
+	return fmt.Sprintf("wss://%s.tradingview.com/socket.io/websocket?from=screener%%2F", e)
+}
+
+const defaultEndpoint = EndpointProData
+
+// WithTokenProvider sets the auth token provider used when initializing
+// connections, instead of the package-level AuthTokenManager
+func WithTokenProvider(provider AuthTokenManagerInterface) Option {
+	return func(c *Client) {
+		c.tokenProvider = provider
+	}
+}
+
+// WithURL sets the WebSocket endpoint the client connects to
+func WithURL(url string) Option {
+	return func(c *Client) {
+		c.wsURL = url
+	}
+}
+
+// WithEndpoint connects to one of the TradingView data hosts
+func WithEndpoint(endpoint Endpoint) Option {
+	return func(c *Client) {
+		switch endpoint {
+		case EndpointProData, EndpointData, EndpointWidgetData:
+			c.wsURL = endpoint.URL()
+		default:
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_endpoint",
+				fmt.Sprintf("unknown endpoint %q", endpoint), nil))
+		}
+	}
+}
+
+// WithReconnectPolicy sets how the client retries lost connections. The
+// default is exponential backoff with full jitter between 1s and 30s, giving
+// up after the configured number of retries.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(c *Client) {
+		c.reconnectPolicy = policy
+	}
+}
+
+// WithMaxRetries sets how many reconnection attempts the default reconnect
+// policy makes before giving up. It has no effect with WithReconnectPolicy.
+func WithMaxRetries(retries int) Option {
+	return func(c *Client) {
+		c.maxRetries = retries
+	}
+}
+
+// WithPingInterval sets the interval between WebSocket pings
+func WithPingInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pingInterval = interval
+	}
+}
+
+// WithWriteTimeout sets the deadline for WebSocket writes
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithReadTimeout sets the deadline for WebSocket reads
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithHandshakeTimeout sets the timeout for the WebSocket upgrade and the
+// session info packet that follows it
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.handshakeTimeout = timeout
+		c.handshakeTimeoutSet = true
+	}
+}
+
+// WithHeartbeatTimeout sets how long the client waits for a server ~h~
+// heartbeat before it declares the connection stale and reconnects.
+// TradingView sends one about every 10 seconds; 0 disables the watchdog.
+func WithHeartbeatTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.heartbeatTimeout = timeout
+	}
+}
+
+// WithRateLimit limits outbound messages to perSecond with bursts of up to
+// burst messages. Heartbeat replies are never limited; 0 disables the limit.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) {
+		c.rateLimit = perSecond
+		c.rateBurst = burst
+	}
+}
+
+// WithWriteQueueSize sets how many outbound messages may wait for the writer
+// before sends fail with ErrRateLimitExceeded
+func WithWriteQueueSize(size int) Option {
+	return func(c *Client) {
+		c.writeQueueSize = size
+	}
+}
+
+// WithHeader sets a header sent with the WebSocket upgrade request, replacing
+// any default value
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.requestHeader.Set(key, value)
+	}
+}
+
+// WithHeaders replaces the headers sent with the WebSocket upgrade request
+func WithHeaders(header http.Header) Option {
+	return func(c *Client) {
+		c.requestHeader = header.Clone()
+		if c.requestHeader == nil {
+			c.requestHeader = http.Header{}
+		}
+	}
+}
+
+// WithDialer sets the dialer used to open connections. WithProxyURL,
+// WithTLSConfig and WithHandshakeTimeout override the corresponding fields
+// of a copy of the dialer.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		if dialer == nil {
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_dialer", "dialer must not be nil", nil))
+			return
+		}
+		c.dialer = dialer
+	}
+}
+
+// WithProxyURL routes connections through an http, https or socks5 proxy
+func WithProxyURL(proxyURL string) Option {
+	return func(c *Client) {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_proxy_url", "invalid proxy URL", err))
+			return
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_proxy_url",
+				fmt.Sprintf("unsupported proxy scheme %q", u.Scheme), nil))
+			return
+		}
+		c.proxyURL = u
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for wss:// connections
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithLogger sets the logger used by the client instead of slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		if logger == nil {
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_logger", "logger must not be nil", nil))
+			return
+		}
+		c.logger = logger
+	}
+}
+
+// WithTimezone sets the timezone chart sessions use to align bars, e.g.
+// "America/New_York". The default is Etc/UTC.
+func WithTimezone(timezone string) Option {
+	return func(c *Client) {
+		if err := validateTimezone("with_timezone", timezone); err != nil {
+			c.optionErrors = append(c.optionErrors, err)
+			return
+		}
+		c.timezone = timezone
+	}
+}
+
+// WithLocale sets the language and country sent with set_locale, e.g. "ja" and "JP"
+func WithLocale(language, country string) Option {
+	return func(c *Client) {
+		if language == "" || country == "" {
+			c.optionErrors = append(c.optionErrors, WrapValidationError("with_locale",
+				fmt.Sprintf("invalid locale %q/%q", language, country), nil))
+			return
+		}
+		c.localeLanguage = language
+		c.localeCountry = country
+	}
+}
+
+// WithTokenRefreshLead sets how long before its expiry the token provider's
+// token is renewed while the client is open. The default is 5 minutes; 0
+// disables the background refresh.
+func WithTokenRefreshLead(lead time.Duration) Option {
+	return func(c *Client) {
+		c.tokenRefreshLead = lead
+	}
+}
+
+// WithBarSeriesLimit sets how many bars each BarSeries keeps, dropping the
+// oldest ones beyond it. The default is 5000; 0 keeps every bar.
+func WithBarSeriesLimit(limit int) Option {
+	return func(c *Client) {
+		c.barSeriesLimit = limit
+	}
+}
+
+// validate checks the configuration produced by the options
+func (c *Client) validate() error {
+	if len(c.optionErrors) > 0 {
+		return c.optionErrors[0]
+	}
+
+	u, err := url.Parse(c.wsURL)
+	if err != nil {
+		return WrapValidationError("new_client", "invalid WebSocket URL", err)
+	}
+	if (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return WrapValidationError("new_client", fmt.Sprintf("WebSocket URL %q must be an absolute ws:// or wss:// URL", c.wsURL), nil)
+	}
+
+	if c.heartbeatTimeout < 0 {
+		return WrapValidationError("new_client", fmt.Sprintf("heartbeat timeout must not be negative, got %v", c.heartbeatTimeout), nil)
+	}
+	if c.rateLimit < 0 || (c.rateLimit > 0 && c.rateBurst < 1) {
+		return WrapValidationError("new_client", fmt.Sprintf("invalid rate limit %v/s with burst %d", c.rateLimit, c.rateBurst), nil)
+	}
+	if c.writeQueueSize < 1 {
+		return WrapValidationError("new_client", fmt.Sprintf("write queue size must be at least 1, got %d", c.writeQueueSize), nil)
+	}
+	if c.tokenRefreshLead < 0 {
+		return WrapValidationError("new_client", fmt.Sprintf("token refresh lead must not be negative, got %v", c.tokenRefreshLead), nil)
+	}
+	if c.barSeriesLimit < 0 {
+		return WrapValidationError("new_client", fmt.Sprintf("bar series limit must not be negative, got %d", c.barSeriesLimit), nil)
+	}
+	if c.maxRetries < 1 {
+		return WrapValidationError("new_client", fmt.Sprintf("max retries must be at least 1, got %d", c.maxRetries), nil)
+	}
+	for _, timeout := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"ping interval", c.pingInterval},
+		{"write timeout", c.writeTimeout},
+		{"read timeout", c.readTimeout},
+		{"handshake timeout", c.handshakeTimeout},
+	} {
+		if timeout.d <= 0 {
+			return WrapValidationError("new_client", fmt.Sprintf("%s must be positive, got %v", timeout.name, timeout.d), nil)
+		}
+	}
+	return nil
+}
+
+// newDialer returns the dialer for the next connection attempt
+func (c *Client) newDialer() *websocket.Dialer {
+	var dialer websocket.Dialer
+	if c.dialer != nil {
+		dialer = *c.dialer
+	}
+	// Keep the timeout of a custom dialer unless WithHandshakeTimeout overrides it
+	if c.dialer == nil || c.handshakeTimeoutSet {
+		dialer.HandshakeTimeout = c.handshakeTimeout
+	}
+	if c.proxyURL != nil {
+		dialer.Proxy = http.ProxyURL(c.proxyURL)
+	}
+	if c.tlsConfig != nil {
+		dialer.TLSClientConfig = c.tlsConfig
+	}
+	return &dialer
+}
@@ -0,0 +1,103 @@
+package tvwsclient
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewClientValidatesOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"relative url", WithURL("/socket.io/websocket")},
+		{"http url", WithURL("https://prodata.tradingview.com/socket.io/websocket")},
+		{"unknown endpoint", WithEndpoint("realtime")},
+		{"zero retries", WithMaxRetries(0)},
+		{"zero ping interval", WithPingInterval(0)},
+		{"negative write timeout", WithWriteTimeout(-time.Second)},
+		{"zero read timeout", WithReadTimeout(0)},
+		{"zero handshake timeout", WithHandshakeTimeout(0)},
+		{"nil dialer", WithDialer(nil)},
+		{"ftp proxy", WithProxyURL("ftp://proxy.local:21")},
+		{"nil logger", WithLogger(nil)},
+		{"unknown timezone", WithTimezone("Mars/Olympus_Mons")},
+		{"empty locale", WithLocale("", "US")},
+		{"negative token refresh lead", WithTokenRefreshLead(-time.Second)},
+		{"negative bar series limit", WithBarSeriesLimit(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(tt.opt)
+			var tvErr *TradingViewError
+			if !errors.As(err, &tvErr) || tvErr.Code != ErrCodeValidation {
+				t.Fatalf("NewClient() error = %v, want %s", err, ErrCodeValidation)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	base := &websocket.Dialer{ReadBufferSize: 4096}
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+
+	c := &Client{requestHeader: defaultHeaders()}
+	for _, opt := range []Option{
+		WithEndpoint(EndpointWidgetData),
+		WithHeader("User-Agent", "tvws-test"),
+		WithDialer(base),
+		WithProxyURL("socks5://127.0.0.1:1080"),
+		WithTLSConfig(tlsConfig),
+		WithHandshakeTimeout(3 * time.Second),
+	} {
+		opt(c)
+	}
+
+	if want := "wss://widgetdata.tradingview.com/socket.io/websocket?from=screener%2F"; c.wsURL != want {
+		t.Errorf("wsURL = %q, want %q", c.wsURL, want)
+	}
+	if got := c.requestHeader.Get("User-Agent"); got != "tvws-test" {
+		t.Errorf("User-Agent = %q, want tvws-test", got)
+	}
+
+	dialer := c.newDialer()
+	if dialer == base || dialer.ReadBufferSize != 4096 {
+		t.Errorf("newDialer() should copy the base dialer")
+	}
+	if dialer.TLSClientConfig != tlsConfig || dialer.HandshakeTimeout != 3*time.Second {
+		t.Errorf("newDialer() did not apply TLS config and handshake timeout")
+	}
+	proxy, err := dialer.Proxy(&http.Request{})
+	if err != nil || proxy.String() != "socks5://127.0.0.1:1080" {
+		t.Errorf("dialer proxy = %v, %v", proxy, err)
+	}
+	if base.TLSClientConfig != nil || base.Proxy != nil {
+		t.Errorf("newDialer() modified the base dialer")
+	}
+}
+
+func TestDialerHandshakeTimeout(t *testing.T) {
+	custom := &websocket.Dialer{HandshakeTimeout: 45 * time.Second}
+
+	c := &Client{handshakeTimeout: 10 * time.Second}
+	WithDialer(custom)(c)
+	if got := c.newDialer().HandshakeTimeout; got != 45*time.Second {
+		t.Errorf("HandshakeTimeout = %v, want the custom dialer's 45s", got)
+	}
+
+	WithHandshakeTimeout(3 * time.Second)(c)
+	if got := c.newDialer().HandshakeTimeout; got != 3*time.Second {
+		t.Errorf("HandshakeTimeout = %v, want 3s from WithHandshakeTimeout", got)
+	}
+
+	c = &Client{handshakeTimeout: 10 * time.Second}
+	if got := c.newDialer().HandshakeTimeout; got != 10*time.Second {
+		t.Errorf("HandshakeTimeout = %v, want the 10s default", got)
+	}
+}
@@ -0,0 +1,94 @@
+package tvwsclient
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// ReconnectPolicy decides how the client retries a lost connection. attempt
+// counts the failed connection attempts of the current outage, starting at 1.
+type ReconnectPolicy interface {
+	// NextDelay returns how long to wait before the next attempt
+	NextDelay(attempt int) time.Duration
+	// ShouldRetry reports whether to try again after attempt failed with err
+	ShouldRetry(attempt int, err error) bool
+	// Reset is called once a connection has been re-established
+	Reset()
+}
+
+// ExponentialBackoff waits a random duration between zero and
+// Base*2^(attempt-1), capped at Max ("full jitter")
+type ExponentialBackoff struct {
+	Base        time.Duration
+	Max         time.Duration
+	MaxAttempts int // 0 retries forever
+}
+
+// NewExponentialBackoff returns an exponential backoff policy with full jitter
+func NewExponentialBackoff(base, max time.Duration, maxAttempts int) *ExponentialBackoff {
+	return &ExponentialBackoff{Base: base, Max: max, MaxAttempts: maxAttempts}
+}
+
+// NextDelay implements ReconnectPolicy
+func (p *ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	ceiling := p.Max
+	if attempt < 1 {
+		attempt = 1
+	}
+	// Stop doubling before the shift overflows
+	if attempt <= 32 {
+		if d := p.Base << uint(attempt-1); d > 0 && (p.Max <= 0 || d < p.Max) {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// ShouldRetry implements ReconnectPolicy
+func (p *ExponentialBackoff) ShouldRetry(attempt int, err error) bool {
+	return p.MaxAttempts <= 0 || attempt < p.MaxAttempts
+}
+
+// Reset implements ReconnectPolicy
+func (p *ExponentialBackoff) Reset() {}
+
+// ConstantBackoff waits the same delay between attempts
+type ConstantBackoff struct {
+	Delay       time.Duration
+	MaxAttempts int // 0 retries forever
+}
+
+// NewConstantBackoff returns a policy that waits delay between attempts
+func NewConstantBackoff(delay time.Duration, maxAttempts int) *ConstantBackoff {
+	return &ConstantBackoff{Delay: delay, MaxAttempts: maxAttempts}
+}
+
+// NextDelay implements ReconnectPolicy
+func (p *ConstantBackoff) NextDelay(attempt int) time.Duration {
+	return p.Delay
+}
+
+// ShouldRetry implements ReconnectPolicy
+func (p *ConstantBackoff) ShouldRetry(attempt int, err error) bool {
+	return p.MaxAttempts <= 0 || attempt < p.MaxAttempts
+}
+
+// Reset implements ReconnectPolicy
+func (p *ConstantBackoff) Reset() {}
+
+// InfiniteRetry wraps policy so that the client never gives up, keeping the
+// delays of the wrapped policy
+func InfiniteRetry(policy ReconnectPolicy) ReconnectPolicy {
+	return infiniteRetry{policy}
+}
+
+type infiniteRetry struct {
+	ReconnectPolicy
+}
+
+func (infiniteRetry) ShouldRetry(int, error) bool {
+	return true
+}
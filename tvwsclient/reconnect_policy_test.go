@@ -0,0 +1,146 @@
+package tvwsclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	p := NewExponentialBackoff(100*time.Millisecond, time.Second, 3)
+
+	for attempt, ceiling := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		70: time.Second,
+	} {
+		for i := 0; i < 50; i++ {
+			if d := p.NextDelay(attempt); d < 0 || d > ceiling {
+				t.Fatalf("NextDelay(%d) = %v, want within [0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+
+	if !p.ShouldRetry(2, nil) || p.ShouldRetry(3, nil) {
+		t.Errorf("ShouldRetry should allow 3 attempts in total")
+	}
+	if !InfiniteRetry(p).ShouldRetry(100, errors.New("boom")) {
+		t.Errorf("InfiniteRetry should never give up")
+	}
+}
+
+func TestConstantBackoff(t *testing.T) {
+	p := NewConstantBackoff(50*time.Millisecond, 0)
+	if d := p.NextDelay(10); d != 50*time.Millisecond {
+		t.Errorf("NextDelay() = %v, want 50ms", d)
+	}
+	if !p.ShouldRetry(1000, nil) {
+		t.Errorf("ShouldRetry() with MaxAttempts 0 should retry forever")
+	}
+}
+
+// recordingPolicy retries a fixed number of times with a constant delay
+type recordingPolicy struct {
+	mu       sync.Mutex
+	attempts []int
+	resets   int
+	max      int
+	delay    time.Duration
+}
+
+func (p *recordingPolicy) NextDelay(attempt int) time.Duration { return p.delay }
+
+func (p *recordingPolicy) ShouldRetry(attempt int, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts = append(p.attempts, attempt)
+	return attempt < p.max
+}
+
+func (p *recordingPolicy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resets++
+}
+
+func TestReconnectPolicyGivesUp(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	policy := &recordingPolicy{max: 3}
+	client := newTestClient(t, srv, WithReconnectPolicy(policy))
+
+	result := make(chan error, 1)
+	go func() { result <- client.Run(context.Background()) }()
+
+	srv.SetRejectConnections(true)
+	srv.DropConnections()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrReconnectFailed) {
+			t.Fatalf("Run() error = %v, want ErrReconnectFailed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not give up")
+	}
+	if err := client.Wait(); !errors.Is(err, ErrReconnectFailed) {
+		t.Errorf("Wait() error = %v, want ErrReconnectFailed", err)
+	}
+
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	if len(policy.attempts) != 3 || policy.attempts[2] != 3 {
+		t.Errorf("ShouldRetry attempts = %v, want [1 2 3]", policy.attempts)
+	}
+}
+
+func TestReconnectPolicyResetOnSuccess(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	policy := &recordingPolicy{max: 100, delay: 10 * time.Millisecond}
+	client := newTestClient(t, srv, WithReconnectPolicy(policy))
+	go client.Run(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := srv.WaitForMessages(ctx, "set_locale", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.SetRejectConnections(true)
+	srv.DropConnections()
+	time.Sleep(50 * time.Millisecond)
+	srv.SetRejectConnections(false)
+
+	if err := srv.WaitForConnections(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitForMessages(ctx, "set_auth_token", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		policy.mu.Lock()
+		resets := policy.resets
+		policy.mu.Unlock()
+		if resets == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Reset() called %d times, want 1", resets)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !client.IsConnected() {
+		t.Errorf("IsConnected() = false after reconnect")
+	}
+}
@@ -0,0 +1,70 @@
+package tvwsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Request is an outbound protocol message, encoded as {"m":method,"p":params}
+type Request struct {
+	Method string        `json:"m"`
+	Params []interface{} `json:"p"`
+}
+
+// NewRequest builds a request for method with the given parameters
+func NewRequest(method string, params ...interface{}) Request {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return Request{Method: method, Params: params}
+}
+
+// Encode returns the JSON payload of the request. HTML characters are not
+// escaped so symbols such as "M&M" are sent verbatim.
+func (r Request) Encode() (string, error) {
+	payload, err := marshalJSON(r)
+	if err != nil {
+		return "", WrapMessageError("encode_request", fmt.Errorf("%s: %w", r.Method, err))
+	}
+	return payload, nil
+}
+
+// encodeSymbolDescriptor returns the "={...}" form TradingView uses to pass a
+// symbol together with its adjustment, session and currency settings. Keys are
+// emitted in sorted order and empty values are omitted.
+func encodeSymbolDescriptor(fields map[string]string) string {
+	object := make(map[string]string, len(fields))
+	for key, value := range fields {
+		if value != "" {
+			object[key] = value
+		}
+	}
+	// A map of strings always marshals
+	payload, _ := marshalJSON(object)
+	return "=" + payload
+}
+
+// marshalJSON encodes v without HTML escaping or a trailing newline
+func marshalJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// sendRequest queues req and waits until the writer goroutine has written it
+func (c *Client) sendRequest(req Request) error {
+	return c.sendRequestWithPriority(req, priorityNormal)
+}
+
+func (c *Client) sendRequestWithPriority(req Request, priority writePriority) error {
+	payload, err := req.Encode()
+	if err != nil {
+		return err
+	}
+	return c.enqueue(wrappedMessage(payload), req.Method, priority)
+}
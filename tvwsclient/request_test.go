@@ -0,0 +1,138 @@
+package tvwsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestRequestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "no params",
+			req:  NewRequest("quote_hibernate_all"),
+			want: `{"m":"quote_hibernate_all","p":[]}`,
+		},
+		{
+			name: "mixed params",
+			req:  NewRequest("create_series", "cs_1", "sds_1", "s1", "sds_sym_1", "1D", int64(300), ""),
+			want: `{"m":"create_series","p":["cs_1","sds_1","s1","sds_sym_1","1D",300,""]}`,
+		},
+		{
+			name: "html is not escaped",
+			req:  NewRequest("quote_add_symbols", "qs_1", "NYSE:M&M<1>"),
+			want: `{"m":"quote_add_symbols","p":["qs_1","NYSE:M&M<1>"]}`,
+		},
+		{
+			name: "quotes and backslashes",
+			req:  NewRequest("quote_add_symbols", "qs_1", `A"B\C`),
+			want: `{"m":"quote_add_symbols","p":["qs_1","A\"B\\C"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.Encode()
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Encode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestEncodeError(t *testing.T) {
+	_, err := NewRequest("bad", make(chan int)).Encode()
+	if err == nil {
+		t.Fatal("Encode() error = nil, want error for unsupported parameter")
+	}
+	var tvErr *TradingViewError
+	if !errors.As(err, &tvErr) || tvErr.Code != ErrCodeMessage {
+		t.Errorf("Encode() error = %v, want %s", err, ErrCodeMessage)
+	}
+}
+
+func TestEncodeSymbolDescriptor(t *testing.T) {
+	got := encodeSymbolDescriptor(map[string]string{
+		"symbol":      `NASDAQ:"X"`,
+		"adjustment":  "splits",
+		"session":     "",
+		"currency-id": "USD",
+	})
+	want := `={"adjustment":"splits","currency-id":"USD","symbol":"NASDAQ:\"X\""}`
+	if got != want {
+		t.Errorf("encodeSymbolDescriptor() = %s, want %s", got, want)
+	}
+
+	// The descriptor must survive being embedded as a request parameter
+	payload, err := NewRequest("resolve_symbol", "cs_1", "sds_sym_1", got).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Request
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+		t.Fatalf("payload %s is not valid JSON: %v", payload, err)
+	}
+	if decoded.Params[2] != got {
+		t.Errorf("decoded descriptor = %v, want %s", decoded.Params[2], got)
+	}
+}
+
+func TestGetQuoteFastSymbolsMessageParams(t *testing.T) {
+	got := getQuoteFastSymbolsMessageParams("NASDAQ:NTLA", MediumParameters)
+	want := []string{
+		`={"adjustment":"dividends","backadjustment":"default","currency-id":"USD","session":"extended","symbol":"NASDAQ:NTLA"}`,
+		`={"adjustment":"dividends","backadjustment":"default","currency-id":"USD","symbol":"NASDAQ:NTLA"}`,
+		"NASDAQ:NTLA",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getQuoteFastSymbolsMessageParams() = %q, want %q", got, want)
+	}
+}
+
+func TestSendMessagesEscapeSymbols(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	symbol := `EXCH:"QUOTED"\SYM&`
+	if err := SendQuoteAddSymbolsMessageWithType(client, "qs_test", symbol, LessParameters); err != nil {
+		t.Fatalf("SendQuoteAddSymbolsMessageWithType() error = %v", err)
+	}
+	if err := SendQuoteCompletedMessageAfterQuoteCompleted(client, "qs_test", symbol); err != nil {
+		t.Fatalf("SendQuoteCompletedMessageAfterQuoteCompleted() error = %v", err)
+	}
+
+	added, err := srv.WaitForMessages(ctx, "quote_add_symbols", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var descriptor map[string]string
+	if err := json.Unmarshal([]byte(added[0].ParamString(1)[1:]), &descriptor); err != nil {
+		t.Fatalf("descriptor %q is not valid JSON: %v", added[0].ParamString(1), err)
+	}
+	if descriptor["symbol"] != symbol {
+		t.Errorf("descriptor symbol = %q, want %q", descriptor["symbol"], symbol)
+	}
+
+	removed, err := srv.WaitForMessages(ctx, "quote_remove_symbols", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := removed[0].ParamString(1); got != symbol {
+		t.Errorf("quote_remove_symbols symbol = %q, want %q", got, symbol)
+	}
+}
@@ -1,12 +1,7 @@
 package tvwsclient
 
 import (
-	"fmt"
-	"log/slog"
 	"strings"
-	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 // Add these fields as a constant since they're used in quote set fields
@@ -16,6 +11,11 @@ const defaultQuoteFields = "base-currency-logoid,ch,chp,currency-logoid,currency
 	"pro_name,short_name,type,typespecs,update_mode,volume,variable_tick_size,value_unit_id," +
 	"unit_id,measure"
 
+// Symbol variants for SendQuoteAddSymbolsMessageWithType and
+// SendQuoteFastSymbolsMessageWithType.
+//
+// Deprecated: build a SymbolDescriptor and use SendQuoteAddSymbolsMessage or
+// SendQuoteFastSymbolsMessage instead.
 const (
 	OnlySymbol       = "only_symbol"
 	LessParameters   = "less_parameters"
@@ -24,134 +24,192 @@ const (
 	MostParameters   = "most_parameters"
 )
 
-// sendWSMessage is a helper function that handles the common pattern of sending websocket messages
-func sendWSMessage(ws *websocket.Conn, message string, operation string) error {
-	if ws == nil {
-		return fmt.Errorf("websocket connection is nil, cannot send %s", operation)
-	}
-	
-	wrappedMsg := wrappedMessage(message)
-	slog.Debug("Send Message", "message", wrappedMsg)
-	if err := ws.WriteMessage(websocket.TextMessage, []byte(wrappedMsg)); err != nil {
-		return fmt.Errorf("error sending %s: %w", operation, err)
-	}
-	// Small delay between messages
-	time.Sleep(100 * time.Millisecond)
-	return nil
-}
-
 func SendSetAuthTokenMessage(c *Client, authToken string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"set_auth_token","p":["%s"]}`, authToken)
-	return sendWSMessage(ws, message, "set auth token message")
+	return c.sendRequestWithPriority(NewRequest("set_auth_token", authToken), priorityHigh)
 }
 
 func SendSetLocalMessage(c *Client) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := `{"m":"set_locale","p":["en","US"]}`
-	return sendWSMessage(ws, message, "set local message")
+	return c.sendRequestWithPriority(NewRequest("set_locale", c.localeLanguage, c.localeCountry), priorityHigh)
 }
 
 // Chart Messages
 func SendChartCreateSessionMessage(c *Client, session string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"chart_create_session","p":["%s",""]}`, session)
-	return sendWSMessage(ws, message, "chart create session message")
+	return c.sendRequest(NewRequest("chart_create_session", session, ""))
 }
 
+// SendSwitchTimezoneMessage sets the timezone of a chart session to the one
+// selected with SetChartSessionTimezone, or the client timezone
 func SendSwitchTimezoneMessage(c *Client, session string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"switch_timezone","p":["%s","Etc/UTC"]}`, session)
-	return sendWSMessage(ws, message, "switch timezone message")
+	return c.sendRequest(NewRequest("switch_timezone", session, c.SessionTimezone(session)))
 }
 
+// SetChartSessionTimezone switches an existing chart session to timezone and
+// keeps it across reconnects
+func SetChartSessionTimezone(c *Client, session string, timezone string) error {
+	if err := validateTimezone("set_chart_session_timezone", timezone); err != nil {
+		return err
+	}
+	c.sessionTimezones.set(session, timezone)
+	c.subscriptions.setTimezone(session, timezone)
+	return SendSwitchTimezoneMessage(c, session)
+}
+
+// SendResolveSymbolMessage resolves symbol with split adjustment and regular
+// hours. A symbol already in ={...} form, such as SymbolDescriptor.String(),
+// is sent unchanged.
 func SendResolveSymbolMessage(c *Client, session string, symbol string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"resolve_symbol","p":["%s","sds_sym_1","={\"adjustment\":\"splits\",\"session\":\"regular\",\"symbol\":\"%s\"}"]}`, session, symbol)
-	return sendWSMessage(ws, message, "resolve symbol")
+	return sendResolveSymbol(c, session, "sds_sym_1", symbol)
+}
+
+func sendResolveSymbol(c *Client, session string, symbolID string, symbol string) error {
+	if !strings.HasPrefix(symbol, "=") {
+		symbol = SymbolDescriptor{Symbol: symbol, Adjustment: AdjustmentSplits, Session: SessionRegular}.String()
+	}
+	return c.sendRequest(NewRequest("resolve_symbol", session, symbolID, symbol))
+}
+
+// SendResolveSymbolDescriptorMessage resolves the symbol described by descriptor
+// as sds_sym_1
+func SendResolveSymbolDescriptorMessage(c *Client, session string, descriptor SymbolDescriptor) error {
+	return SendResolveSymbolDescriptorMessageWithID(c, session, "sds_sym_1", descriptor)
+}
+
+// SendResolveSymbolDescriptorMessageWithID resolves the symbol described by
+// descriptor as symbolID, e.g. the ChartSeries.SymbolID of a series
+func SendResolveSymbolDescriptorMessageWithID(c *Client, session string, symbolID string, descriptor SymbolDescriptor) error {
+	if err := descriptor.Validate(); err != nil {
+		return err
+	}
+	return sendResolveSymbol(c, session, symbolID, descriptor.String())
 }
 
+// SendCreateSeriesMessage creates series sds_1 with interval, a resolution
+// accepted by ParseInterval
 func SendCreateSeriesMessage(c *Client, session string, interval string, seriesNumber int64) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"create_series","p":["%s","sds_1","s1","sds_sym_1","%s",%d,""]}`, session, interval, seriesNumber)
-	return sendWSMessage(ws, message, "chart create session message")
+	interval, err := normalizeInterval(interval)
+	if err != nil {
+		return err
+	}
+	return sendCreateSeries(c, session, newChartSeries(1, "", interval, seriesNumber))
+}
+
+func sendCreateSeries(c *Client, session string, series ChartSeries) error {
+	return c.sendRequest(NewRequest("create_series", session, series.ID, series.Turnaround, series.SymbolID,
+		series.Interval, series.BarCount, series.rangeParam()))
+}
+
+// SendModifySeriesMessage switches an existing series to the symbol, interval
+// and range of series
+func SendModifySeriesMessage(c *Client, session string, series ChartSeries) error {
+	rng := series.Range
+	if !rng.IsTimeRange() {
+		rng = BarCount(series.BarCount)
+	}
+	return c.sendRequest(NewRequest("modify_series", session, series.ID, series.Turnaround, series.SymbolID,
+		series.Interval, rng.param()))
+}
+
+// SendRemoveSeriesMessage removes a series from a chart session
+func SendRemoveSeriesMessage(c *Client, session string, seriesID string) error {
+	return c.sendRequest(NewRequest("remove_series", session, seriesID))
 }
 
 // Chart Messages
 func SendChartDeleteSessionMessage(c *Client, session string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"chart_delete_session","p":["%s",""]}`, session)
-	return sendWSMessage(ws, message, "chart remove session message")
+	if err := c.sendRequest(NewRequest("chart_delete_session", session, "")); err != nil {
+		return err
+	}
+	c.subscriptions.remove(session)
+	c.sessionTimezones.remove(session)
+	c.bars.remove(session, "")
+	return nil
 }
 
+// SubscriptionChartSessionSymbol creates a chart session for symbol and registers it
+// so it is re-created automatically after a reconnect. interval is a resolution
+// accepted by ParseInterval.
 func SubscriptionChartSessionSymbol(client *Client, session string, symbol string, interval string, seriesNumber int64) error {
-	if err := SendChartCreateSessionMessage(client, session); err != nil {
-		slog.Error("failed to send chart create session message ", "error", err)
+	interval, err := normalizeInterval(interval)
+	if err != nil {
+		return err
+	}
+	if err := sendChartSubscription(client, session, symbol, interval, seriesNumber); err != nil {
 		return err
 	}
+	client.subscriptions.addChart(session, symbol, interval, seriesNumber, client.SessionTimezone(session))
+	return nil
+}
 
-	if err := SendSwitchTimezoneMessage(client, session); err != nil {
-		slog.Error("failed to send switch timezone message ", "error", err)
+// SubscriptionChartSessionSymbolWithTimezone is SubscriptionChartSessionSymbol
+// with bars aligned to timezone, e.g. "America/New_York" or "Asia/Tokyo"
+func SubscriptionChartSessionSymbolWithTimezone(client *Client, session string, symbol string, interval string, seriesNumber int64, timezone string) error {
+	if err := validateTimezone("subscription_chart_session", timezone); err != nil {
+		return err
+	}
+	client.sessionTimezones.set(session, timezone)
+	if err := SubscriptionChartSessionSymbol(client, session, symbol, interval, seriesNumber); err != nil {
+		client.sessionTimezones.remove(session)
 		return err
 	}
+	return nil
+}
+
+func sendChartSubscription(client *Client, session string, symbol string, interval string, seriesNumber int64) error {
+	return sendChartSession(client, session, []ChartSeries{newChartSeries(1, symbol, interval, seriesNumber)})
+}
 
-	if err := SendResolveSymbolMessage(client, session, symbol); err != nil {
-		slog.Error("failed to send resolve symbol message ", "error", err)
+// sendChartSession creates a chart session with all of its series
+func sendChartSession(client *Client, session string, series []ChartSeries) error {
+	if err := SendChartCreateSessionMessage(client, session); err != nil {
+		client.logger.Error("failed to send chart create session message ", "error", err)
 		return err
 	}
 
-	if err := SendCreateSeriesMessage(client, session, interval, seriesNumber); err != nil {
-		slog.Error("failed to send create series message ", "error", err)
+	if err := SendSwitchTimezoneMessage(client, session); err != nil {
+		client.logger.Error("failed to send switch timezone message ", "error", err)
 		return err
 	}
+
+	for _, s := range series {
+		if err := sendResolveSymbol(client, session, s.SymbolID, s.Symbol); err != nil {
+			client.logger.Error("failed to send resolve symbol message ", "error", err)
+			return err
+		}
+
+		if err := sendCreateSeries(client, session, s); err != nil {
+			client.logger.Error("failed to send create series message ", "error", err)
+			return err
+		}
+	}
 	return nil
 }
 
 // Quote Messages
 func SendQuoteCreateSessionMessage(c *Client, session string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"quote_create_session","p":["%s"]}`, session)
-	if err := sendWSMessage(ws, message, "quote create session message"); err != nil {
+	if err := c.sendRequest(NewRequest("quote_create_session", session)); err != nil {
+		return err
+	}
+	c.subscriptions.addQuoteSession(session)
+	return nil
+}
+
+// SendQuoteDeleteSessionMessage deletes a quote session and unregisters it, so
+// it is no longer re-created after a reconnect
+func SendQuoteDeleteSessionMessage(c *Client, session string) error {
+	if err := c.sendRequest(NewRequest("quote_delete_session", session)); err != nil {
 		return err
 	}
+	c.subscriptions.remove(session)
 	return nil
 }
 
 func SendQuoteSetFieldsMessage(c *Client, session string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
 	fields := strings.Split(defaultQuoteFields, ",")
-	message := fmt.Sprintf(`{"m":"quote_set_fields","p":["%s",%s]}`,
-		session,
-		`"`+strings.Join(fields, `","`)+`"`,
-	)
-	return sendWSMessage(ws, message, "quote set fields message")
+	if err := c.sendRequest(NewRequest("quote_set_fields", stringParams(session, fields)...)); err != nil {
+		return err
+	}
+	c.subscriptions.setQuoteFields(session, fields)
+	return nil
 }
 
 // func SendQuoteFastSymbolsMessage(c *Client, session string, symbols []string) error {
@@ -180,33 +238,29 @@ func SendQuoteSetFieldsMessage(c *Client, session string) error {
 // }
 
 func SendQuoteRemoveSymbolsMessage(c *Client, session string, symbols []string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	message := fmt.Sprintf(`{"m":"quote_remove_symbols","p":["%s","%s"]}`,
-		session,
-		strings.Join(symbols, `","`),
-	)
-	return sendWSMessage(ws, message, "quote remove symbols message")
+	if err := c.sendRequest(NewRequest("quote_remove_symbols", stringParams(session, symbols)...)); err != nil {
+		return err
+	}
+	c.subscriptions.removeQuoteSymbols(session, symbols)
+	return nil
 }
 
 func SendQuoteCompletedMessageAfterQuoteCompleted(c *Client, session string, receivedMessage string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	// Replace single backslash + quote with triple backslash + quote
-	receivedMessage = strings.ReplaceAll(receivedMessage, `\`, `\\`)
-	// Replace remaining quotes with escaped quotes
-	receivedMessage = strings.ReplaceAll(receivedMessage, `"`, `\"`)
-	message := fmt.Sprintf(`{"m":"quote_remove_symbols","p":["%s","%s"]}`,
-		session,
-		receivedMessage,
-	)
-	return sendWSMessage(ws, message, "remove quote message after quote completed message")
+	return c.sendRequest(NewRequest("quote_remove_symbols", session, receivedMessage))
 }
 
+// stringParams returns session followed by values as request parameters
+func stringParams(session string, values []string) []interface{} {
+	params := make([]interface{}, 0, len(values)+1)
+	params = append(params, session)
+	for _, value := range values {
+		params = append(params, value)
+	}
+	return params
+}
+
+// SubscriptionQuoteSessionSymbol creates a quote session for symbol and registers it
+// so it is re-created automatically after a reconnect
 func SubscriptionQuoteSessionSymbol(client *Client, session string, symbol string) error {
 	if err := SendQuoteCreateSessionMessage(client, session); err != nil {
 		return err
@@ -256,9 +310,25 @@ func SubscriptionQuoteSessionSymbol(client *Client, session string, symbol strin
 	// 	return err
 	// }
 
-	if err := SendQuoteAddSymbolsMessageWithType(client, session, symbol, OnlySymbol); err != nil {
+	return SendQuoteAddSymbolsMessageWithType(client, session, symbol, OnlySymbol)
+}
+
+// sendQuoteSubscription re-creates a quote session with all of its symbols
+func sendQuoteSubscription(client *Client, session string, symbols []string, fields []string) error {
+	if err := SendQuoteCreateSessionMessage(client, session); err != nil {
 		return err
 	}
 
+	if fields != nil {
+		if err := SendQuoteSetFieldsMessage(client, session); err != nil {
+			return err
+		}
+	}
+
+	for _, symbol := range symbols {
+		if err := SendQuoteAddSymbolsMessageWithType(client, session, symbol, OnlySymbol); err != nil {
+			return err
+		}
+	}
 	return nil
 }
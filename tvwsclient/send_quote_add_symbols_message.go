@@ -1,38 +1,60 @@
 package tvwsclient
 
-import (
-	"fmt"
-)
+// SendQuoteAddSymbolsMessage adds the described symbols to a quote session
+func SendQuoteAddSymbolsMessage(c *Client, session string, descriptors ...SymbolDescriptor) error {
+	params, err := descriptorParams(descriptors)
+	if err != nil {
+		return err
+	}
+	return sendQuoteAddSymbols(c, session, params)
+}
 
+// Deprecated: use SendQuoteAddSymbolsMessage with a SymbolDescriptor.
 func SendQuoteAddSymbolsMessageWithType(c *Client, session string, symbol string, symbolType string) error {
-	c.mu.Lock()
-	ws := c.ws
-	c.mu.Unlock()
-	
-	params := getQuoteAddSymbolsMessageParams(symbol, symbolType)
-	message := fmt.Sprintf(`{"m":"quote_add_symbols","p":["%s","%s"]}`,
-		session,
-		params,
-	)
-	return sendWSMessage(ws, message, "quote add symbols message")
+	return sendQuoteAddSymbols(c, session, getQuoteAddSymbolsMessageParams(symbol, symbolType))
+}
+
+// sendQuoteAddSymbols sends quote_add_symbols and registers every symbol so
+// it is added again after a reconnect
+func sendQuoteAddSymbols(c *Client, session string, symbols []string) error {
+	if err := c.sendRequest(NewRequest("quote_add_symbols", stringParams(session, symbols)...)); err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		c.subscriptions.addQuoteSymbol(session, symbol)
+	}
+	return nil
 }
 
-func getQuoteAddSymbolsMessageParams(symbol string, symbolType string) string {
+func getQuoteAddSymbolsMessageParams(symbol string, symbolType string) []string {
 	switch symbolType {
 	case OnlySymbol:
-		return symbol
+		return []string{symbol}
 	case LessParameters:
 		// "={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"symbol\":\"NASDAQ:NTLA\"}"
-		return `={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"symbol\":\"` + symbol + `\"}`
+		return []string{quoteSymbolDescriptor(symbol, "", "")}
 	case MediumParameters:
 		// "={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"currency-id\":\"USD\",\"symbol\":\"NASDAQ:NTLA\"}"
-		return `={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"currency-id\":\"USD\",\"symbol\":\"` + symbol + `\"}`
+		return []string{quoteSymbolDescriptor(symbol, "USD", "")}
 	case MoreParameters:
 		// "={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"session\":\"extended\",\"symbol\":\"NASDAQ:NTLA\"}"
-		return `={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"session\":\"extended\",\"symbol\":\"` + symbol + `\"}`
+		return []string{quoteSymbolDescriptor(symbol, "", SessionExtended)}
 	case MostParameters:
 		// "={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"currency-id\":\"USD\",\"session\":\"extended\",\"symbol\":\"NASDAQ:NTLA\"}"
-		return `={\"adjustment\":\"dividends\",\"backadjustment\":\"default\",\"currency-id\":\"USD\",\"session\":\"extended\",\"symbol\":\"` + symbol + `\"}`
+		return []string{quoteSymbolDescriptor(symbol, "USD", SessionExtended)}
 	}
-	return symbol
+	return []string{symbol}
+}
+
+// quoteSymbolDescriptor returns the dividend-adjusted descriptor the
+// deprecated symbol variants send, optionally converted to currency and
+// limited to session
+func quoteSymbolDescriptor(symbol, currency string, session TradingSession) string {
+	return SymbolDescriptor{
+		Symbol:         symbol,
+		Adjustment:     AdjustmentDividends,
+		BackAdjustment: BackAdjustmentDefault,
+		CurrencyID:     currency,
+		Session:        session,
+	}.String()
 }
@@ -0,0 +1,103 @@
+package tvwsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ServerInfo holds the session info packet the server sends right after the WebSocket handshake
+type ServerInfo struct {
+	SessionID           string    `json:"session_id"`
+	Timestamp           int64     `json:"timestamp"`
+	TimestampMs         int64     `json:"timestampMs"`
+	Release             string    `json:"release"`
+	StudiesMetadataHash string    `json:"studies_metadata_hash"`
+	AuthSchemeVersion   int       `json:"auth_scheme_vsn"`
+	Protocol            string    `json:"protocol"`
+	Via                 string    `json:"via"`
+	JavaStudies         []string  `json:"-"` // sent either as a single string or a list
+	Raw                 string    `json:"-"` // packet payload as received
+	ReceivedAt          time.Time `json:"-"`
+}
+
+// ParseServerInfo decodes a session info packet. Plain-text payloads are kept in Raw.
+func ParseServerInfo(payload []byte) (*ServerInfo, error) {
+	info := &ServerInfo{
+		Raw:        string(payload),
+		ReceivedAt: time.Now(),
+	}
+	if len(payload) == 0 || payload[0] != '{' {
+		return info, nil
+	}
+
+	if err := json.Unmarshal(payload, info); err != nil {
+		return nil, WrapMessageError("parse_server_info", fmt.Errorf("%w: %v", ErrInvalidMessage, err))
+	}
+
+	var extra struct {
+		JavaStudies json.RawMessage `json:"javastudies"`
+	}
+	if err := json.Unmarshal(payload, &extra); err == nil && len(extra.JavaStudies) > 0 {
+		var single string
+		if err := json.Unmarshal(extra.JavaStudies, &single); err == nil {
+			info.JavaStudies = []string{single}
+		} else if err := json.Unmarshal(extra.JavaStudies, &info.JavaStudies); err != nil {
+			return nil, WrapMessageError("parse_server_info", fmt.Errorf("%w: invalid javastudies: %v", ErrInvalidMessage, err))
+		}
+	}
+	return info, nil
+}
+
+// isServerInfoPacket reports whether packet is a session info packet rather than a method call
+func isServerInfoPacket(packet Packet) bool {
+	switch packet.Kind {
+	case PacketSessionInfo:
+		return true
+	case PacketJSON:
+		var probe struct {
+			Method *string `json:"m"`
+		}
+		return json.Unmarshal(packet.Payload, &probe) == nil && probe.Method == nil && !bytes.Equal(packet.Payload, []byte("{}"))
+	}
+	return false
+}
+
+// readServerInfo waits for the session info packet on a freshly dialled connection
+func readServerInfo(conn *websocket.Conn, timeout time.Duration) (*ServerInfo, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server hello: %w", err)
+	}
+
+	packets, err := DecodeFrame(message)
+	if err != nil {
+		return nil, err
+	}
+	for _, packet := range packets {
+		if isServerInfoPacket(packet) {
+			return ParseServerInfo(packet.Payload)
+		}
+	}
+	return nil, WrapMessageError("read_server_info", fmt.Errorf("%w: first frame is not a session info packet", ErrInvalidMessage))
+}
+
+// ServerInfo returns the session info received during the last handshake, or nil before the first connection
+func (c *Client) ServerInfo() *ServerInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.serverInfo == nil {
+		return nil
+	}
+	info := *c.serverInfo
+	info.JavaStudies = append([]string(nil), c.serverInfo.JavaStudies...)
+	return &info
+}
@@ -0,0 +1,63 @@
+package tvwsclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseServerInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		sessionID   string
+		javaStudies []string
+	}{
+		{
+			name:        "javastudies list",
+			payload:     `{"session_id":"<0.1.2>_abc","timestamp":1736302609,"timestampMs":1736302609050,"release":"registry:5000/tvbs_release/webchart:release_207","studies_metadata_hash":"abc123","auth_scheme_vsn":2,"protocol":"json","via":"10.0.0.1:443","javastudies":["3.66"]}`,
+			sessionID:   "<0.1.2>_abc",
+			javaStudies: []string{"3.66"},
+		},
+		{
+			name:        "javastudies string",
+			payload:     `{"session_id":"<0.3.4>_def","timestamp":1736302609,"release":"release_206","javastudies":"javastudies-3.61_955"}`,
+			sessionID:   "<0.3.4>_def",
+			javaStudies: []string{"javastudies-3.61_955"},
+		},
+		{
+			name:    "plain text",
+			payload: `session-info`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseServerInfo([]byte(tt.payload))
+			if err != nil {
+				t.Fatalf("ParseServerInfo() error = %v", err)
+			}
+			if info.SessionID != tt.sessionID {
+				t.Errorf("SessionID = %q, want %q", info.SessionID, tt.sessionID)
+			}
+			if !reflect.DeepEqual(info.JavaStudies, tt.javaStudies) {
+				t.Errorf("JavaStudies = %v, want %v", info.JavaStudies, tt.javaStudies)
+			}
+			if info.Raw != tt.payload {
+				t.Errorf("Raw = %q, want %q", info.Raw, tt.payload)
+			}
+		})
+	}
+}
+
+func TestIsServerInfoPacket(t *testing.T) {
+	packets, err := DecodeFrame([]byte(EncodeFrame(`{"session_id":"x","timestamp":1}`, `{"m":"qsd","p":[]}`, `~h~1`, `text`)))
+	if err != nil {
+		t.Fatalf("DecodeFrame() error = %v", err)
+	}
+	want := []bool{true, false, false, true}
+	for i, packet := range packets {
+		if got := isServerInfoPacket(packet); got != want[i] {
+			t.Errorf("isServerInfoPacket(%q) = %v, want %v", packet.Payload, got, want[i])
+		}
+	}
+}
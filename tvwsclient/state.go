@@ -0,0 +1,133 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConnectionState describes the lifecycle of the client connection
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateAuthenticating // session info received, sending auth token and locale
+	StateReconnecting   // connection lost, the reconnect policy is retrying
+	StateClosed         // Close was called or the client context was cancelled
+	StateFailed         // the reconnect policy gave up; the client is closed and Wait returns the error
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateAuthenticating:
+		return "authenticating"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("ConnectionState(%d)", int(s))
+}
+
+// StateChange describes a connection state transition
+type StateChange struct {
+	From ConnectionState
+	To   ConnectionState
+	Err  error // cause of the transition, nil for expected transitions
+	At   time.Time
+}
+
+// stateNotifier delivers state changes to listeners in order, outside of the client lock
+type stateNotifier struct {
+	mu          sync.Mutex
+	listeners   map[uint64]func(StateChange)
+	nextID      uint64
+	queue       []StateChange
+	dispatching bool
+}
+
+func newStateNotifier() *stateNotifier {
+	return &stateNotifier{listeners: make(map[uint64]func(StateChange))}
+}
+
+func (n *stateNotifier) subscribe(listener func(StateChange)) func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	id := n.nextID
+	n.nextID++
+	n.listeners[id] = listener
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			delete(n.listeners, id)
+		})
+	}
+}
+
+// notify queues change; a single goroutine drains the queue so listeners see transitions in order
+func (n *stateNotifier) notify(change StateChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.listeners) == 0 {
+		return
+	}
+	n.queue = append(n.queue, change)
+	if !n.dispatching {
+		n.dispatching = true
+		go n.dispatch()
+	}
+}
+
+func (n *stateNotifier) dispatch() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.dispatching = false
+			n.mu.Unlock()
+			return
+		}
+		change := n.queue[0]
+		n.queue = n.queue[1:]
+		listeners := make([]func(StateChange), 0, len(n.listeners))
+		for _, listener := range n.listeners {
+			listeners = append(listeners, listener)
+		}
+		n.mu.Unlock()
+
+		for _, listener := range listeners {
+			listener(change)
+		}
+	}
+}
+
+// setStateLocked records a transition and notifies listeners; c.mu must be held.
+// A closed client never leaves StateClosed.
+func (c *Client) setStateLocked(to ConnectionState, err error) {
+	from := c.state
+	if from == to || from == StateClosed {
+		return
+	}
+	c.state = to
+	c.logger.Debug("connection state changed", "from", from, "to", to, "error", err)
+	c.stateListeners.notify(StateChange{From: from, To: to, Err: err, At: time.Now()})
+}
+
+// OnStateChange registers listener to be called on every connection state
+// transition. Listeners are called in order from a separate goroutine and may
+// call back into the client. The returned function unregisters the listener.
+func (c *Client) OnStateChange(listener func(StateChange)) func() {
+	return c.stateListeners.subscribe(listener)
+}
@@ -0,0 +1,138 @@
+package tvwsclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestConnectionStateString(t *testing.T) {
+	if got := StateReconnecting.String(); got != "reconnecting" {
+		t.Errorf("StateReconnecting.String() = %q", got)
+	}
+	if got := ConnectionState(42).String(); got != "ConnectionState(42)" {
+		t.Errorf("ConnectionState(42).String() = %q", got)
+	}
+}
+
+// stateRecorder collects transitions reported by OnStateChange
+type stateRecorder struct {
+	mu      sync.Mutex
+	changes []StateChange
+}
+
+func (r *stateRecorder) record(change StateChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+// waitFor blocks until a transition to state has been recorded and returns it
+func (r *stateRecorder) waitFor(t *testing.T, state ConnectionState) StateChange {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, change := range r.changes {
+			if change.To == state {
+				r.mu.Unlock()
+				return change
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no transition to %s", state)
+	return StateChange{}
+}
+
+func TestOnStateChange(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithReconnectPolicy(NewConstantBackoff(10*time.Millisecond, 0)))
+	if got := client.GetConnectionState(); got != StateConnected {
+		t.Fatalf("GetConnectionState() = %s, want connected", got)
+	}
+
+	recorder := &stateRecorder{}
+	unsubscribe := client.OnStateChange(recorder.record)
+	go client.Run(context.Background())
+
+	srv.DropConnections()
+
+	lost := recorder.waitFor(t, StateReconnecting)
+	if lost.From != StateConnected || lost.Err == nil || lost.At.IsZero() {
+		t.Errorf("reconnecting transition = %+v, want from connected with a cause", lost)
+	}
+	recorder.waitFor(t, StateAuthenticating)
+	restored := recorder.waitFor(t, StateConnected)
+	if restored.From != StateAuthenticating {
+		t.Errorf("connected transition from %s, want authenticating", restored.From)
+	}
+
+	unsubscribe()
+	client.Close()
+	if got := client.GetConnectionState(); got != StateClosed {
+		t.Errorf("GetConnectionState() after Close = %s, want closed", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for _, change := range recorder.changes {
+		if change.To == StateClosed {
+			t.Errorf("listener called after unsubscribe: %+v", change)
+		}
+	}
+}
+
+func TestStateFailedWhenPolicyGivesUp(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithReconnectPolicy(NewConstantBackoff(time.Millisecond, 2)))
+	recorder := &stateRecorder{}
+	client.OnStateChange(recorder.record)
+	go client.Run(context.Background())
+
+	srv.SetRejectConnections(true)
+	srv.DropConnections()
+
+	failed := recorder.waitFor(t, StateFailed)
+	if !errors.Is(failed.Err, ErrReconnectFailed) {
+		t.Errorf("failed transition error = %v, want ErrReconnectFailed", failed.Err)
+	}
+	client.Wait()
+	if got := client.GetConnectionState(); got != StateFailed {
+		t.Errorf("GetConnectionState() = %s, want failed", got)
+	}
+}
+
+func TestStateFailedClosesClientWithoutReadLoop(t *testing.T) {
+	srv := tvwstest.NewServer(tvwstest.WithHeartbeatInterval(0))
+	defer srv.Close()
+
+	client := newTestClient(t, srv,
+		WithHeartbeatTimeout(100*time.Millisecond),
+		WithReconnectPolicy(NewConstantBackoff(time.Millisecond, 1)))
+	srv.SetRejectConnections(true)
+
+	// The heartbeat watchdog starts the reconnect; nothing calls Run
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrReconnectFailed) {
+			t.Errorf("Wait() error = %v, want ErrReconnectFailed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after the reconnect policy gave up")
+	}
+	if got := client.GetConnectionState(); got != StateFailed {
+		t.Errorf("GetConnectionState() = %s, want failed", got)
+	}
+}
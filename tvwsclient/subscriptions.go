@@ -0,0 +1,313 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SubscriptionType identifies the kind of session a subscription refers to
+type SubscriptionType string
+
+const (
+	SubscriptionChart SubscriptionType = "chart"
+	SubscriptionQuote SubscriptionType = "quote"
+)
+
+// Subscription describes an active chart or quote session that the client
+// re-creates after a successful reconnect
+type Subscription struct {
+	Type         SubscriptionType
+	SessionID    string
+	Symbol       string        // chart sessions only
+	Interval     string        // chart sessions only
+	SeriesNumber int64         // chart sessions only
+	Timezone     string        // chart sessions only
+	Series       []ChartSeries // chart sessions only, in creation order
+	Symbols      []string      // quote sessions only
+	QuoteFields  []string      // quote sessions only, nil when the server defaults are used
+	CreatedAt    time.Time
+
+	seq uint64 // registration order
+}
+
+// SubscriptionFailure pairs a subscription with the error that prevented its restoration
+type SubscriptionFailure struct {
+	Subscription Subscription
+	Err          error
+}
+
+// RestoreReport describes the outcome of replaying the subscription registry after a reconnect
+type RestoreReport struct {
+	Restored []Subscription
+	Failed   []SubscriptionFailure
+}
+
+// subscriptionRegistry keeps track of the sessions created through the client
+type subscriptionRegistry struct {
+	mu       sync.RWMutex
+	sessions map[string]*Subscription
+	seq      uint64
+}
+
+func newSubscriptionRegistry() *subscriptionRegistry {
+	return &subscriptionRegistry{
+		sessions: make(map[string]*Subscription),
+	}
+}
+
+func (r *subscriptionRegistry) addChart(session, symbol, interval string, seriesNumber int64, timezone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	r.sessions[session] = &Subscription{
+		Type:         SubscriptionChart,
+		SessionID:    session,
+		Symbol:       symbol,
+		Interval:     interval,
+		SeriesNumber: seriesNumber,
+		Timezone:     timezone,
+		Series:       []ChartSeries{newChartSeries(1, symbol, interval, seriesNumber)},
+		CreatedAt:    time.Now(),
+		seq:          r.seq,
+	}
+}
+
+// addChartSession registers a chart session without series
+func (r *subscriptionRegistry) addChartSession(session, timezone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	r.sessions[session] = &Subscription{
+		Type:      SubscriptionChart,
+		SessionID: session,
+		Timezone:  timezone,
+		CreatedAt: time.Now(),
+		seq:       r.seq,
+	}
+}
+
+// addSeries adds or replaces a series of a chart session. The first series
+// is also reflected in the single-series fields.
+func (r *subscriptionRegistry) addSeries(session string, series ChartSeries) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, exists := r.sessions[session]
+	if !exists || sub.Type != SubscriptionChart {
+		return
+	}
+	replaced := false
+	for i := range sub.Series {
+		if sub.Series[i].ID == series.ID {
+			sub.Series[i] = series
+			replaced = true
+		}
+	}
+	if !replaced {
+		sub.Series = append(sub.Series, series)
+	}
+	sub.syncFirstSeries()
+}
+
+func (r *subscriptionRegistry) removeSeries(session, seriesID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, exists := r.sessions[session]
+	if !exists || sub.Type != SubscriptionChart {
+		return
+	}
+	kept := sub.Series[:0]
+	for _, series := range sub.Series {
+		if series.ID != seriesID {
+			kept = append(kept, series)
+		}
+	}
+	sub.Series = kept
+	sub.syncFirstSeries()
+}
+
+// lookupSeries returns a series of a chart session by its ID
+func (r *subscriptionRegistry) lookupSeries(session, seriesID string) (ChartSeries, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sub, exists := r.sessions[session]
+	if !exists {
+		return ChartSeries{}, false
+	}
+	for _, series := range sub.Series {
+		if series.ID == seriesID {
+			return series, true
+		}
+	}
+	return ChartSeries{}, false
+}
+
+// syncFirstSeries mirrors the first series into Symbol, Interval and SeriesNumber
+func (s *Subscription) syncFirstSeries() {
+	s.Symbol, s.Interval, s.SeriesNumber = "", "", 0
+	if len(s.Series) > 0 {
+		s.Symbol = s.Series[0].Symbol
+		s.Interval = s.Series[0].Interval
+		s.SeriesNumber = s.Series[0].BarCount
+	}
+}
+
+func (r *subscriptionRegistry) setTimezone(session, timezone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sub, exists := r.sessions[session]; exists && sub.Type == SubscriptionChart {
+		sub.Timezone = timezone
+	}
+}
+
+// addQuoteSession registers a quote session, keeping the fields and symbols
+// of an existing registration
+func (r *subscriptionRegistry) addQuoteSession(session string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quoteSession(session)
+}
+
+func (r *subscriptionRegistry) addQuoteSymbol(session, symbol string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub := r.quoteSession(session)
+	for _, s := range sub.Symbols {
+		if s == symbol {
+			return
+		}
+	}
+	sub.Symbols = append(sub.Symbols, symbol)
+}
+
+func (r *subscriptionRegistry) setQuoteFields(session string, fields []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quoteSession(session).QuoteFields = append([]string(nil), fields...)
+}
+
+// quoteSession returns the registered quote session, creating it if needed.
+// r.mu must be held.
+func (r *subscriptionRegistry) quoteSession(session string) *Subscription {
+	sub, exists := r.sessions[session]
+	if !exists || sub.Type != SubscriptionQuote {
+		r.seq++
+		sub = &Subscription{
+			Type:      SubscriptionQuote,
+			SessionID: session,
+			CreatedAt: time.Now(),
+			seq:       r.seq,
+		}
+		r.sessions[session] = sub
+	}
+	return sub
+}
+
+func (r *subscriptionRegistry) removeQuoteSymbols(session string, symbols []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, exists := r.sessions[session]
+	if !exists || sub.Type != SubscriptionQuote {
+		return
+	}
+	remove := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		remove[s] = true
+	}
+	kept := sub.Symbols[:0]
+	for _, s := range sub.Symbols {
+		if !remove[s] {
+			kept = append(kept, s)
+		}
+	}
+	sub.Symbols = kept
+}
+
+func (r *subscriptionRegistry) remove(session string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, session)
+}
+
+func (r *subscriptionRegistry) get(session string) (Subscription, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sub, exists := r.sessions[session]
+	if !exists {
+		return Subscription{}, false
+	}
+	return sub.clone(), true
+}
+
+// list returns a snapshot of all subscriptions in registration order
+func (r *subscriptionRegistry) list() []Subscription {
+	r.mu.RLock()
+	subs := make([]Subscription, 0, len(r.sessions))
+	for _, sub := range r.sessions {
+		subs = append(subs, sub.clone())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].seq < subs[j].seq
+	})
+	return subs
+}
+
+func (s *Subscription) clone() Subscription {
+	c := *s
+	c.Symbols = append([]string(nil), s.Symbols...)
+	c.Series = append([]ChartSeries(nil), s.Series...)
+	if s.QuoteFields != nil {
+		c.QuoteFields = append([]string(nil), s.QuoteFields...)
+	}
+	return c
+}
+
+// Subscriptions returns the chart and quote sessions the client will restore after a reconnect
+func (c *Client) Subscriptions() []Subscription {
+	return c.subscriptions.list()
+}
+
+// SetRestoreCallback sets a callback function to be called after the subscription
+// registry has been replayed on a new connection
+func (c *Client) SetRestoreCallback(callback func(*RestoreReport)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRestore = callback
+}
+
+// restoreSubscriptions re-creates every registered session on the current connection
+func (c *Client) restoreSubscriptions() *RestoreReport {
+	report := &RestoreReport{}
+	for _, sub := range c.subscriptions.list() {
+		var err error
+		switch sub.Type {
+		case SubscriptionChart:
+			err = sendChartSession(c, sub.SessionID, sub.Series)
+		case SubscriptionQuote:
+			err = sendQuoteSubscription(c, sub.SessionID, sub.Symbols, sub.QuoteFields)
+		default:
+			err = fmt.Errorf("unknown subscription type %q", sub.Type)
+		}
+
+		if err != nil {
+			c.logger.Error("failed to restore subscription",
+				"session", sub.SessionID,
+				"type", sub.Type,
+				"error", err)
+			report.Failed = append(report.Failed, SubscriptionFailure{
+				Subscription: sub,
+				Err:          WrapSessionError("restore_subscription", err),
+			})
+			continue
+		}
+		report.Restored = append(report.Restored, sub)
+	}
+
+	c.logger.Info("subscriptions restored",
+		"restored", len(report.Restored),
+		"failed", len(report.Failed))
+	return report
+}
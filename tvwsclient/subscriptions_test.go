@@ -0,0 +1,87 @@
+package tvwsclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubscriptionRegistry(t *testing.T) {
+	t.Run("chart sessions", func(t *testing.T) {
+		r := newSubscriptionRegistry()
+		r.addChart("cs_1", "BINANCE:BTCUSDT", "1D", 300, "Etc/UTC")
+
+		sub, ok := r.get("cs_1")
+		if !ok {
+			t.Fatal("chart session should be registered")
+		}
+		if sub.Type != SubscriptionChart || sub.Symbol != "BINANCE:BTCUSDT" || sub.Interval != "1D" || sub.SeriesNumber != 300 {
+			t.Errorf("unexpected chart subscription: %+v", sub)
+		}
+
+		r.remove("cs_1")
+		if _, ok := r.get("cs_1"); ok {
+			t.Error("chart session should be removed")
+		}
+	})
+
+	t.Run("quote sessions", func(t *testing.T) {
+		r := newSubscriptionRegistry()
+		r.addQuoteSymbol("qs_1", "NASDAQ:AAPL")
+		r.addQuoteSymbol("qs_1", "NASDAQ:MSFT")
+		r.addQuoteSymbol("qs_1", "NASDAQ:AAPL")
+
+		sub, _ := r.get("qs_1")
+		if want := []string{"NASDAQ:AAPL", "NASDAQ:MSFT"}; !reflect.DeepEqual(sub.Symbols, want) {
+			t.Errorf("Symbols = %v, want %v", sub.Symbols, want)
+		}
+		if sub.QuoteFields != nil {
+			t.Errorf("QuoteFields = %v, want nil", sub.QuoteFields)
+		}
+
+		r.setQuoteFields("qs_1", []string{"lp", "ch"})
+		r.removeQuoteSymbols("qs_1", []string{"NASDAQ:AAPL"})
+
+		sub, _ = r.get("qs_1")
+		if want := []string{"NASDAQ:MSFT"}; !reflect.DeepEqual(sub.Symbols, want) {
+			t.Errorf("Symbols = %v, want %v", sub.Symbols, want)
+		}
+		if want := []string{"lp", "ch"}; !reflect.DeepEqual(sub.QuoteFields, want) {
+			t.Errorf("QuoteFields = %v, want %v", sub.QuoteFields, want)
+		}
+	})
+
+	t.Run("quote fields before symbols", func(t *testing.T) {
+		r := newSubscriptionRegistry()
+		r.addQuoteSession("qs_1")
+		r.setQuoteFields("qs_1", []string{"lp"})
+		r.addQuoteSymbol("qs_1", "NASDAQ:AAPL")
+		r.addQuoteSession("qs_1")
+
+		sub, ok := r.get("qs_1")
+		if !ok || sub.Type != SubscriptionQuote {
+			t.Fatalf("get() = %+v, %v, want a quote session", sub, ok)
+		}
+		if !reflect.DeepEqual(sub.QuoteFields, []string{"lp"}) || !reflect.DeepEqual(sub.Symbols, []string{"NASDAQ:AAPL"}) {
+			t.Errorf("QuoteFields = %v, Symbols = %v", sub.QuoteFields, sub.Symbols)
+		}
+	})
+
+	t.Run("list returns snapshots", func(t *testing.T) {
+		r := newSubscriptionRegistry()
+		r.addChart("cs_1", "NASDAQ:AAPL", "60", 100, "Etc/UTC")
+		r.addQuoteSymbol("qs_1", "NASDAQ:AAPL")
+
+		subs := r.list()
+		if len(subs) != 2 {
+			t.Fatalf("list() returned %d subscriptions, want 2", len(subs))
+		}
+		if subs[0].SessionID != "cs_1" || subs[1].SessionID != "qs_1" {
+			t.Errorf("list() should be in registration order, got %s, %s", subs[0].SessionID, subs[1].SessionID)
+		}
+
+		subs[1].Symbols[0] = "MUTATED"
+		if sub, _ := r.get("qs_1"); sub.Symbols[0] != "NASDAQ:AAPL" {
+			t.Error("mutating a snapshot should not affect the registry")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Adjustment selects how historical prices are adjusted for corporate actions
+type Adjustment string
+
+const (
+	AdjustmentSplits    Adjustment = "splits"    // adjust for splits only
+	AdjustmentDividends Adjustment = "dividends" // adjust for splits and dividends
+	AdjustmentNone      Adjustment = "none"      // raw prices
+)
+
+// TradingSession selects the trading hours a symbol is streamed for
+type TradingSession string
+
+const (
+	SessionRegular  TradingSession = "regular"  // regular trading hours
+	SessionExtended TradingSession = "extended" // pre- and post-market included
+)
+
+// BackAdjustment selects how continuous futures contracts are back-adjusted
+type BackAdjustment string
+
+const (
+	BackAdjustmentDefault BackAdjustment = "default"
+	BackAdjustmentNone    BackAdjustment = "none"
+)
+
+// SymbolDescriptor describes a symbol together with the settings TradingView
+// applies to its data. Empty fields are left to the server default.
+type SymbolDescriptor struct {
+	Symbol         string         // e.g. "NASDAQ:AAPL"
+	Adjustment     Adjustment     // price adjustment
+	Session        TradingSession // trading hours
+	CurrencyID     string         // convert prices to this currency, e.g. "USD"
+	BackAdjustment BackAdjustment // futures back-adjustment
+	UnitID         string         // convert values to this unit
+}
+
+// String returns the ={...} form used by resolve_symbol and quote sessions
+func (d SymbolDescriptor) String() string {
+	return encodeSymbolDescriptor(map[string]string{
+		"adjustment":     string(d.Adjustment),
+		"backadjustment": string(d.BackAdjustment),
+		"currency-id":    d.CurrencyID,
+		"session":        string(d.Session),
+		"symbol":         d.Symbol,
+		"unit-id":        d.UnitID,
+	})
+}
+
+// Validate checks that the symbol is set and the enumerated fields are known
+func (d SymbolDescriptor) Validate() error {
+	if strings.TrimSpace(d.Symbol) == "" {
+		return WrapValidationError("symbol_descriptor", "symbol must not be empty", ErrInvalidSymbol)
+	}
+	switch d.Adjustment {
+	case "", AdjustmentSplits, AdjustmentDividends, AdjustmentNone:
+	default:
+		return WrapValidationError("symbol_descriptor", fmt.Sprintf("unknown adjustment %q", d.Adjustment), ErrInvalidSymbol)
+	}
+	switch d.Session {
+	case "", SessionRegular, SessionExtended:
+	default:
+		return WrapValidationError("symbol_descriptor", fmt.Sprintf("unknown session %q", d.Session), ErrInvalidSymbol)
+	}
+	switch d.BackAdjustment {
+	case "", BackAdjustmentDefault, BackAdjustmentNone:
+	default:
+		return WrapValidationError("symbol_descriptor", fmt.Sprintf("unknown backadjustment %q", d.BackAdjustment), ErrInvalidSymbol)
+	}
+	return nil
+}
+
+// descriptorParams validates descriptors and returns their encoded forms
+func descriptorParams(descriptors []SymbolDescriptor) ([]string, error) {
+	params := make([]string, len(descriptors))
+	for i, d := range descriptors {
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+		params[i] = d.String()
+	}
+	return params, nil
+}
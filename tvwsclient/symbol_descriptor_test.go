@@ -0,0 +1,139 @@
+package tvwsclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestSymbolDescriptorString(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor SymbolDescriptor
+		want       string
+	}{
+		{
+			name:       "symbol only",
+			descriptor: SymbolDescriptor{Symbol: "NASDAQ:AAPL"},
+			want:       `={"symbol":"NASDAQ:AAPL"}`,
+		},
+		{
+			name: "all fields",
+			descriptor: SymbolDescriptor{
+				Symbol:         "CME_MINI:ES1!",
+				Adjustment:     AdjustmentDividends,
+				Session:        SessionExtended,
+				CurrencyID:     "EUR",
+				BackAdjustment: BackAdjustmentDefault,
+				UnitID:         "metric_ton",
+			},
+			want: `={"adjustment":"dividends","backadjustment":"default","currency-id":"EUR","session":"extended","symbol":"CME_MINI:ES1!","unit-id":"metric_ton"}`,
+		},
+		{
+			name:       "raw prices",
+			descriptor: SymbolDescriptor{Symbol: "NYSE:M&M", Adjustment: AdjustmentNone, Session: SessionRegular},
+			want:       `={"adjustment":"none","session":"regular","symbol":"NYSE:M&M"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.descriptor.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymbolDescriptorValidate(t *testing.T) {
+	valid := SymbolDescriptor{Symbol: "NASDAQ:AAPL", Adjustment: AdjustmentSplits, Session: SessionRegular}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	for _, d := range []SymbolDescriptor{
+		{},
+		{Symbol: " "},
+		{Symbol: "NASDAQ:AAPL", Adjustment: "bonus"},
+		{Symbol: "NASDAQ:AAPL", Session: "overnight"},
+		{Symbol: "NASDAQ:AAPL", BackAdjustment: "ratio"},
+	} {
+		if err := d.Validate(); !errors.Is(err, ErrInvalidSymbol) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidSymbol", d, err)
+		}
+	}
+}
+
+func TestSendSymbolDescriptors(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chart := SymbolDescriptor{Symbol: testSymbol, Adjustment: AdjustmentNone, Session: SessionExtended}
+	if err := SendChartCreateSessionMessage(client, "cs_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendResolveSymbolDescriptorMessage(client, "cs_test", chart); err != nil {
+		t.Fatalf("SendResolveSymbolDescriptorMessage() error = %v", err)
+	}
+	if err := SendResolveSymbolDescriptorMessageWithID(client, "cs_test", "sds_sym_2", chart); err != nil {
+		t.Fatalf("SendResolveSymbolDescriptorMessageWithID() error = %v", err)
+	}
+	if err := SendResolveSymbolDescriptorMessageWithID(client, "cs_test", "sds_sym_3", SymbolDescriptor{}); !errors.Is(err, ErrInvalidSymbol) {
+		t.Errorf("SendResolveSymbolDescriptorMessageWithID(empty) error = %v, want ErrInvalidSymbol", err)
+	}
+	// Encoded descriptors pass through the string variant unchanged
+	if err := SendResolveSymbolMessage(client, "cs_test", chart.String()); err != nil {
+		t.Fatalf("SendResolveSymbolMessage() error = %v", err)
+	}
+
+	quotes := []SymbolDescriptor{
+		{Symbol: testSymbol},
+		{Symbol: testSymbol, Session: SessionExtended, CurrencyID: "EUR"},
+	}
+	if err := SendQuoteCreateSessionMessage(client, "qs_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendQuoteAddSymbolsMessage(client, "qs_test", quotes...); err != nil {
+		t.Fatalf("SendQuoteAddSymbolsMessage() error = %v", err)
+	}
+	if err := SendQuoteFastSymbolsMessage(client, "qs_test", quotes[1]); err != nil {
+		t.Fatalf("SendQuoteFastSymbolsMessage() error = %v", err)
+	}
+	if err := SendQuoteAddSymbolsMessage(client, "qs_test", SymbolDescriptor{}); !errors.Is(err, ErrInvalidSymbol) {
+		t.Errorf("SendQuoteAddSymbolsMessage(empty) error = %v, want ErrInvalidSymbol", err)
+	}
+
+	resolved, err := srv.WaitForMessages(ctx, "resolve_symbol", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range resolved {
+		if got := m.ParamString(2); got != chart.String() {
+			t.Errorf("resolve_symbol descriptor = %s, want %s", got, chart.String())
+		}
+		if want := []string{"sds_sym_1", "sds_sym_2", "sds_sym_1"}[i]; m.ParamString(1) != want {
+			t.Errorf("resolve_symbol %d symbol ID = %s, want %s", i, m.ParamString(1), want)
+		}
+	}
+
+	added, err := srv.WaitForMessages(ctx, "quote_add_symbols", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := added[0].Params[1:]; len(got) != 2 || got[0] != quotes[0].String() || got[1] != quotes[1].String() {
+		t.Errorf("quote_add_symbols params = %v, want %s and %s", got, quotes[0], quotes[1])
+	}
+	fast, err := srv.WaitForMessages(ctx, "quote_fast_symbols", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fast[0].ParamString(1); got != quotes[1].String() {
+		t.Errorf("quote_fast_symbols descriptor = %s, want %s", got, quotes[1])
+	}
+}
@@ -0,0 +1,84 @@
+package tvwsclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SymbolSearchResult is a single match returned by the symbol search endpoint
+type SymbolSearchResult struct {
+	Symbol           string   `json:"symbol"`
+	Description      string   `json:"description"`
+	Type             string   `json:"type"`
+	Exchange         string   `json:"exchange"`
+	CurrencyCode     string   `json:"currency_code"`
+	ProviderID       string   `json:"provider_id"`
+	SourceID         string   `json:"source_id"`
+	Country          string   `json:"country"`
+	TypeSpecs        []string `json:"typespecs"`
+	Prefix           string   `json:"prefix"`
+	IsPrimaryListing bool     `json:"is_primary_listing"`
+}
+
+// FullName returns the EXCHANGE:SYMBOL form accepted by the chart and quote sessions
+func (r SymbolSearchResult) FullName() string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = r.Exchange
+	}
+	if prefix == "" {
+		return r.Symbol
+	}
+	return prefix + ":" + r.Symbol
+}
+
+// SymbolSearchOptions narrows a symbol search
+type SymbolSearchOptions struct {
+	Exchange   string // e.g. "NASDAQ"
+	SearchType string // e.g. "stock", "crypto", "forex", "futures"
+	Country    string // results from this country are sorted first, defaults to US
+}
+
+// SearchSymbols looks up symbols matching text
+func (c *TVHttpClient) SearchSymbols(ctx context.Context, text string, opts SymbolSearchOptions) ([]SymbolSearchResult, error) {
+	country := opts.Country
+	if country == "" {
+		country = "US"
+	}
+
+	query := url.Values{}
+	query.Set("text", text)
+	query.Set("hl", "1")
+	query.Set("exchange", opts.Exchange)
+	query.Set("lang", "en")
+	query.Set("search_type", opts.SearchType)
+	query.Set("domain", "production")
+	query.Set("sort_by_country", country)
+
+	body, err := c.DoRequestContext(ctx, http.MethodGet, c.searchURL+"/symbol_search/v3/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Symbols []SymbolSearchResult `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, WrapMessageError("search_symbols", fmt.Errorf("%w: %v", ErrInvalidMessage, err))
+	}
+
+	// Matches are highlighted with <em> tags
+	for i := range response.Symbols {
+		response.Symbols[i].Symbol = stripHighlight(response.Symbols[i].Symbol)
+		response.Symbols[i].Description = stripHighlight(response.Symbols[i].Description)
+	}
+	return response.Symbols, nil
+}
+
+func stripHighlight(s string) string {
+	return strings.NewReplacer("<em>", "", "</em>", "").Replace(s)
+}
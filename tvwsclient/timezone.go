@@ -0,0 +1,89 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimezone       = "Etc/UTC"
+	defaultLocaleLanguage = "en"
+	defaultLocaleCountry  = "US"
+)
+
+// validateTimezone checks that timezone is an IANA name such as America/New_York
+func validateTimezone(op, timezone string) error {
+	if timezone == "" {
+		return WrapValidationError(op, "timezone must not be empty", nil)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return WrapValidationError(op, fmt.Sprintf("unknown timezone %q", timezone), err)
+	}
+	return nil
+}
+
+// timezoneLocation returns the location of timezone, or UTC when it is unknown
+func timezoneLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// sessionTimezones holds the timezones selected for individual chart sessions
+type sessionTimezones struct {
+	mu        sync.RWMutex
+	timezones map[string]string
+}
+
+func newSessionTimezones() *sessionTimezones {
+	return &sessionTimezones{timezones: make(map[string]string)}
+}
+
+func (t *sessionTimezones) set(session, timezone string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timezones[session] = timezone
+}
+
+func (t *sessionTimezones) get(session string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	timezone, ok := t.timezones[session]
+	return timezone, ok
+}
+
+func (t *sessionTimezones) remove(session string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.timezones, session)
+}
+
+// Timezone returns the timezone used for chart sessions without their own
+func (c *Client) Timezone() string {
+	return c.timezone
+}
+
+// SessionTimezone returns the timezone in effect for a chart session
+func (c *Client) SessionTimezone(session string) string {
+	if timezone, ok := c.sessionTimezones.get(session); ok {
+		return timezone
+	}
+	return c.timezone
+}
+
+// annotateEvent records the session timezone on bar events
+func (c *Client) annotateEvent(event Event) Event {
+	switch e := event.(type) {
+	case TimescaleUpdateEvent:
+		e.Timezone = c.SessionTimezone(e.ChartSessionID)
+	case BarUpdateEvent:
+		e.Timezone = c.SessionTimezone(e.ChartSessionID)
+	}
+	return event
+}
@@ -0,0 +1,76 @@
+package tvwsclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestClientLocaleAndTimezone(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := tvwstest.NewServer(tvwstest.WithBars(testSymbol, tvwstest.GenerateBars(start, 24*time.Hour, 5)))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithLocale("ja", "JP"), WithTimezone("Asia/Tokyo"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := client.Events(ctx)
+	go client.ReadMessage(nil)
+
+	locale, err := srv.WaitForMessages(ctx, "set_locale", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := locale[0].Params; len(got) != 2 || got[0] != "ja" || got[1] != "JP" {
+		t.Errorf("set_locale params = %v, want [ja JP]", got)
+	}
+
+	if err := SubscriptionChartSessionSymbol(client, "cs_tokyo", testSymbol, "1D", 5); err != nil {
+		t.Fatal(err)
+	}
+	tokyo := waitForEvent[TimescaleUpdateEvent](t, events)
+	if tokyo.ChartSessionID != "cs_tokyo" || tokyo.Timezone != "Asia/Tokyo" {
+		t.Errorf("snapshot %s timezone = %q, want Asia/Tokyo", tokyo.ChartSessionID, tokyo.Timezone)
+	}
+
+	if err := SubscriptionChartSessionSymbolWithTimezone(client, "cs_ny", testSymbol, "1D", 5, "America/New_York"); err != nil {
+		t.Fatal(err)
+	}
+	ny := waitForEvent[TimescaleUpdateEvent](t, events)
+	if ny.ChartSessionID != "cs_ny" || ny.Timezone != "America/New_York" {
+		t.Errorf("snapshot %s timezone = %q, want America/New_York", ny.ChartSessionID, ny.Timezone)
+	}
+	if got := ny.Location().String(); got != "America/New_York" {
+		t.Errorf("Location() = %s, want America/New_York", got)
+	}
+
+	if err := SetChartSessionTimezone(client, "cs_tokyo", "Europe/London"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChartSessionTimezone(client, "cs_tokyo", "Nowhere/City"); err == nil {
+		t.Error("SetChartSessionTimezone() accepted an unknown timezone")
+	}
+
+	switches, err := srv.WaitForMessages(ctx, "switch_timezone", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"cs_tokyo": "Europe/London", "cs_ny": "America/New_York"}
+	for _, m := range switches[1:] {
+		if got := m.ParamString(1); got != want[m.ParamString(0)] {
+			t.Errorf("switch_timezone(%s) = %s, want %s", m.ParamString(0), got, want[m.ParamString(0)])
+		}
+	}
+	if got := switches[0].ParamString(1); got != "Asia/Tokyo" {
+		t.Errorf("first switch_timezone = %s, want Asia/Tokyo", got)
+	}
+
+	for _, sub := range client.Subscriptions() {
+		if sub.Timezone != want[sub.SessionID] {
+			t.Errorf("subscription %s timezone = %q, want %q", sub.SessionID, sub.Timezone, want[sub.SessionID])
+		}
+	}
+}
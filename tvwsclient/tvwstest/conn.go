@@ -0,0 +1,427 @@
+package tvwstest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// conn is a single client connection; session maps are guarded by srv.mu
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	srv     *Server
+	done    chan struct{}
+
+	charts map[string]*chartSession
+	quotes map[string][]string
+}
+
+type chartSession struct {
+	symbols map[string]string // symbol ID -> symbol
+	series  map[string]*series
+}
+
+type series struct {
+	symbolID   string
+	symbol     string
+	turnaround string
+	interval   string
+	step       int64 // bar duration in seconds
+	count      int   // bars requested so far
+	rangeTo    int64 // end of an r,from:to range, 0 for bar count series
+	bars       []Bar // bars sent to the client, indexed like the protocol
+}
+
+// send writes payloads as a single frame
+func (c *conn) send(payloads ...string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteMessage(websocket.TextMessage, []byte(encodeFrame(payloads...)))
+}
+
+// sendRaw writes frame as is
+func (c *conn) sendRaw(frame string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteMessage(websocket.TextMessage, []byte(frame))
+}
+
+func (c *conn) heartbeats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 1; ; n++ {
+		select {
+		case <-ticker.C:
+			c.send("~h~" + strconv.Itoa(n))
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *conn) handle(msg Message) {
+	switch msg.Method {
+	case "chart_create_session":
+		c.srv.mu.Lock()
+		c.charts[msg.ParamString(0)] = &chartSession{
+			symbols: make(map[string]string),
+			series:  make(map[string]*series),
+		}
+		c.srv.mu.Unlock()
+
+	case "chart_delete_session":
+		c.srv.mu.Lock()
+		delete(c.charts, msg.ParamString(0))
+		c.srv.mu.Unlock()
+
+	case "resolve_symbol":
+		c.resolveSymbol(msg.ParamString(0), msg.ParamString(1), parseSymbol(msg.ParamString(2)))
+
+	case "create_series":
+		rangeParam := ""
+		if len(msg.Params) > 6 {
+			rangeParam, _ = msg.Params[6].(string)
+		}
+		c.loadSeries(msg.ParamString(0), msg.ParamString(1), msg.ParamString(2), msg.ParamString(3),
+			msg.ParamString(4), paramInt(msg.Params, 5), rangeParam)
+
+	case "modify_series":
+		// The last parameter is either a bar count or an r,from:to range
+		count, rangeParam := 0, ""
+		if len(msg.Params) > 5 {
+			switch v := msg.Params[5].(type) {
+			case float64:
+				count = int(v)
+			case string:
+				rangeParam = v
+			}
+		}
+		c.loadSeries(msg.ParamString(0), msg.ParamString(1), msg.ParamString(2), msg.ParamString(3),
+			msg.ParamString(4), count, rangeParam)
+
+	case "remove_series":
+		c.srv.mu.Lock()
+		if chart, ok := c.charts[msg.ParamString(0)]; ok {
+			delete(chart.series, msg.ParamString(1))
+		}
+		c.srv.mu.Unlock()
+
+	case "request_more_data":
+		c.requestMoreData(msg.ParamString(0), msg.ParamString(1), paramInt(msg.Params, 2))
+
+	case "quote_create_session":
+		c.srv.mu.Lock()
+		c.quotes[msg.ParamString(0)] = nil
+		c.srv.mu.Unlock()
+
+	case "quote_delete_session":
+		c.srv.mu.Lock()
+		delete(c.quotes, msg.ParamString(0))
+		c.srv.mu.Unlock()
+
+	case "quote_add_symbols", "quote_fast_symbols":
+		c.addQuoteSymbols(msg.ParamString(0), paramsFrom(msg.Params, 1))
+
+	case "quote_remove_symbols":
+		c.removeQuoteSymbols(msg.ParamString(0), paramsFrom(msg.Params, 1))
+	}
+}
+
+func (c *conn) resolveSymbol(sessionID, symbolID, symbol string) {
+	c.srv.mu.Lock()
+	chart, ok := c.charts[sessionID]
+	rejected := c.srv.rejectedSymbols[symbol]
+	if ok && !rejected {
+		chart.symbols[symbolID] = symbol
+	}
+	c.srv.mu.Unlock()
+
+	if !ok {
+		c.send(encodeMessage("critical_error", sessionID, "unknown chart session"))
+		return
+	}
+	if rejected {
+		c.send(encodeMessage("symbol_error", sessionID, symbolID, "invalid symbol"))
+		return
+	}
+
+	exchange, ticker := symbol, symbol
+	if i := strings.Index(symbol, ":"); i >= 0 {
+		exchange, ticker = symbol[:i], symbol[i+1:]
+	}
+	c.send(encodeMessage("symbol_resolved", sessionID, symbolID, map[string]interface{}{
+		"name":                  ticker,
+		"full_name":             symbol,
+		"pro_name":              symbol,
+		"exchange":              exchange,
+		"listed_exchange":       exchange,
+		"description":           symbol,
+		"type":                  "stock",
+		"currency_code":         "USD",
+		"timezone":              "Etc/UTC",
+		"session":               "24x7",
+		"pricescale":            100,
+		"minmov":                1,
+		"has_intraday":          true,
+		"is-tickbars-available": false,
+	}))
+}
+
+func (c *conn) loadSeries(sessionID, seriesID, turnaround, symbolID, interval string, count int, rangeParam string) {
+	c.srv.mu.Lock()
+	chart, ok := c.charts[sessionID]
+	var symbol string
+	if ok {
+		symbol, ok = chart.symbols[symbolID]
+	}
+	if !ok {
+		c.srv.mu.Unlock()
+		c.send(encodeMessage("series_error", sessionID, seriesID, turnaround, "resolve error"))
+		return
+	}
+
+	ser := chart.series[seriesID]
+	if ser == nil {
+		ser = &series{}
+		chart.series[seriesID] = ser
+	}
+	if count == 0 && rangeParam == "" {
+		count = ser.count
+	}
+	ser.symbolID = symbolID
+	ser.symbol = symbol
+	ser.turnaround = turnaround
+	ser.interval = interval
+	ser.step = intervalSeconds(interval)
+	ser.count = count
+	ser.rangeTo = 0
+	if _, to, ok := parseRange(rangeParam); ok {
+		ser.rangeTo = to
+	}
+	ser.bars = lastBars(selectBars(c.srv.bars[symbol], count, rangeParam), c.srv.maxBars)
+	payload := timescaleUpdate(sessionID, seriesID, ser)
+	c.srv.mu.Unlock()
+
+	c.send(encodeMessage("series_loading", sessionID, seriesID, turnaround))
+	c.send(payload)
+	c.send(encodeMessage("series_completed", sessionID, seriesID, "streaming", turnaround,
+		map[string]interface{}{"rt_update_period": 1}))
+}
+
+func (c *conn) requestMoreData(sessionID, seriesID string, count int) {
+	c.srv.mu.Lock()
+	var ser *series
+	if chart, ok := c.charts[sessionID]; ok {
+		ser = chart.series[seriesID]
+	}
+	if ser == nil {
+		c.srv.mu.Unlock()
+		c.send(encodeMessage("critical_error", sessionID, "unknown series "+seriesID))
+		return
+	}
+	if ser.rangeTo != 0 {
+		// Ranged series page back from the range end
+		ser.bars = lastBars(barsUntil(c.srv.bars[ser.symbol], ser.rangeTo), len(ser.bars)+count)
+	} else {
+		ser.count += count
+		ser.bars = selectBars(c.srv.bars[ser.symbol], ser.count, "")
+	}
+	payload := timescaleUpdate(sessionID, seriesID, ser)
+	turnaround := ser.turnaround
+	c.srv.mu.Unlock()
+
+	c.send(payload)
+	c.send(encodeMessage("series_completed", sessionID, seriesID, "streaming", turnaround,
+		map[string]interface{}{"rt_update_period": 1}))
+}
+
+func (c *conn) addQuoteSymbols(sessionID string, params []interface{}) {
+	var payloads []string
+
+	c.srv.mu.Lock()
+	for _, p := range params {
+		name, ok := p.(string)
+		if !ok {
+			continue
+		}
+		c.quotes[sessionID] = append(c.quotes[sessionID], name)
+
+		symbol := parseSymbol(name)
+		values := c.srv.quotes[symbol]
+		if values == nil {
+			if bars := c.srv.bars[symbol]; len(bars) > 0 {
+				last := bars[len(bars)-1]
+				values = map[string]interface{}{"lp": last.Close, "lp_time": last.Time, "volume": last.Volume}
+			}
+		}
+
+		if values == nil {
+			payloads = append(payloads, encodeMessage("qsd", sessionID, map[string]interface{}{
+				"n": name, "s": "error", "errmsg": "invalid symbol", "v": map[string]interface{}{},
+			}))
+		} else {
+			payloads = append(payloads, encodeMessage("qsd", sessionID, map[string]interface{}{
+				"n": name, "s": "ok", "v": values,
+			}))
+		}
+		payloads = append(payloads, encodeMessage("quote_completed", sessionID, name))
+	}
+	c.srv.mu.Unlock()
+
+	for _, payload := range payloads {
+		c.send(payload)
+	}
+}
+
+func (c *conn) removeQuoteSymbols(sessionID string, params []interface{}) {
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+
+	remove := make(map[string]bool)
+	for _, p := range params {
+		if name, ok := p.(string); ok {
+			remove[name] = true
+		}
+	}
+	var kept []string
+	for _, name := range c.quotes[sessionID] {
+		if !remove[name] {
+			kept = append(kept, name)
+		}
+	}
+	c.quotes[sessionID] = kept
+}
+
+// timescaleUpdate builds a timescale_update snapshot of the bars loaded for ser
+func timescaleUpdate(sessionID, seriesID string, ser *series) string {
+	points := make([]interface{}, len(ser.bars))
+	changes := make([]int64, len(ser.bars))
+	for i, bar := range ser.bars {
+		points[i] = map[string]interface{}{"i": i, "v": bar.values()}
+		changes[i] = bar.Time
+	}
+
+	var barCloseTime int64
+	if n := len(ser.bars); n > 0 {
+		barCloseTime = ser.bars[n-1].Time + ser.step
+	}
+
+	return encodeMessage("timescale_update", sessionID, map[string]interface{}{
+		seriesID: map[string]interface{}{
+			"node": "tvwstest",
+			"s":    points,
+			"ns":   map[string]interface{}{"d": "", "indexes": []interface{}{}},
+			"t":    ser.turnaround,
+			"lbs":  map[string]interface{}{"bar_close_time": barCloseTime},
+		},
+		"index":      len(ser.bars) - 1,
+		"zoffset":    0,
+		"changes":    changes,
+		"marks":      []interface{}{},
+		"index_diff": []interface{}{},
+	})
+}
+
+// selectBars returns the last count bars, or the bars inside an r,from:to range
+func selectBars(all []Bar, count int, rangeParam string) []Bar {
+	if from, to, ok := parseRange(rangeParam); ok {
+		var bars []Bar
+		for _, bar := range all {
+			if bar.Time >= from && bar.Time <= to {
+				bars = append(bars, bar)
+			}
+		}
+		return bars
+	}
+	if count <= 0 || count > len(all) {
+		count = len(all)
+	}
+	return append([]Bar(nil), all[len(all)-count:]...)
+}
+
+// lastBars returns the newest n bars, or all of them when n is 0
+func lastBars(bars []Bar, n int) []Bar {
+	if n > 0 && len(bars) > n {
+		return bars[len(bars)-n:]
+	}
+	return bars
+}
+
+// barsUntil returns the bars opened at or before t
+func barsUntil(all []Bar, t int64) []Bar {
+	var bars []Bar
+	for _, bar := range all {
+		if bar.Time <= t {
+			bars = append(bars, bar)
+		}
+	}
+	return bars
+}
+
+func parseRange(rangeParam string) (int64, int64, bool) {
+	if !strings.HasPrefix(rangeParam, "r,") {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(rangeParam[2:], ":", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	from, err1 := strconv.ParseInt(bounds[0], 10, 64)
+	to, err2 := strconv.ParseInt(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+// intervalSeconds returns the duration of a resolution such as 5S, 15, 1D, 1W or 1M
+func intervalSeconds(interval string) int64 {
+	unit := int64(60)
+	digits := interval
+	if n := len(interval); n > 0 {
+		switch interval[n-1] {
+		case 'S':
+			unit, digits = 1, interval[:n-1]
+		case 'H':
+			unit, digits = 3600, interval[:n-1]
+		case 'D':
+			unit, digits = 86400, interval[:n-1]
+		case 'W':
+			unit, digits = 7*86400, interval[:n-1]
+		case 'M':
+			unit, digits = 30*86400, interval[:n-1]
+		}
+	}
+	multiplier := int64(1)
+	if digits != "" {
+		if v, err := strconv.ParseInt(digits, 10, 64); err == nil && v > 0 {
+			multiplier = v
+		}
+	}
+	return unit * multiplier
+}
+
+func paramInt(params []interface{}, i int) int {
+	if i < len(params) {
+		switch v := params[i].(type) {
+		case float64:
+			return int(v)
+		case string:
+			n, _ := strconv.Atoi(v)
+			return n
+		}
+	}
+	return 0
+}
+
+// paramsFrom returns the parameters from index i on, or nil if there are none
+func paramsFrom(params []interface{}, i int) []interface{} {
+	if i < len(params) {
+		return params[i:]
+	}
+	return nil
+}
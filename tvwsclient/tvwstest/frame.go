@@ -0,0 +1,59 @@
+package tvwstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const frameSeparator = "~m~"
+
+// encodeFrame wraps each payload in the ~m~len~m~payload envelope
+func encodeFrame(payloads ...string) string {
+	var b strings.Builder
+	for _, payload := range payloads {
+		b.WriteString(frameSeparator)
+		b.WriteString(strconv.Itoa(len(payload)))
+		b.WriteString(frameSeparator)
+		b.WriteString(payload)
+	}
+	return b.String()
+}
+
+// decodeFrame splits a frame into its payloads using the declared byte lengths
+func decodeFrame(frame string) ([]string, error) {
+	var payloads []string
+	for len(frame) > 0 {
+		if !strings.HasPrefix(frame, frameSeparator) {
+			return nil, fmt.Errorf("missing packet separator in %q", frame)
+		}
+		frame = frame[len(frameSeparator):]
+
+		end := strings.Index(frame, frameSeparator)
+		if end <= 0 {
+			return nil, fmt.Errorf("missing packet length in %q", frame)
+		}
+		length, err := strconv.Atoi(frame[:end])
+		if err != nil || length < 0 || end+len(frameSeparator)+length > len(frame) {
+			return nil, fmt.Errorf("invalid packet length %q", frame[:end])
+		}
+		frame = frame[end+len(frameSeparator):]
+
+		payloads = append(payloads, frame[:length])
+		frame = frame[length:]
+	}
+	return payloads, nil
+}
+
+// encodeMessage builds a {"m":...,"p":[...]} payload
+func encodeMessage(method string, params ...interface{}) string {
+	data, err := json.Marshal(struct {
+		Method string        `json:"m"`
+		Params []interface{} `json:"p"`
+	}{method, params})
+	if err != nil {
+		panic(fmt.Sprintf("tvwstest: cannot encode %s message: %v", method, err))
+	}
+	return string(data)
+}
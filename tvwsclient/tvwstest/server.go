@@ -0,0 +1,503 @@
+// Package tvwstest provides an in-process fake TradingView WebSocket server
+// for testing code built on tvwsclient without network access.
+//
+// The server speaks the ~m~len~m~payload framing, greets every connection
+// with a session info packet, sends and acknowledges ~h~ heartbeats and
+// answers chart and quote session requests with scripted qsd,
+// symbol_resolved, timescale_update, series_completed and du messages.
+// Disconnects, rejected connections and response delays can be injected
+// to exercise reconnect logic.
+package tvwstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Bar is an OHLCV bar served for a symbol
+type Bar struct {
+	Time   int64 // unix seconds
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+func (b Bar) values() []float64 {
+	return []float64{float64(b.Time), b.Open, b.High, b.Low, b.Close, b.Volume}
+}
+
+// GenerateBars returns n deterministic bars starting at start and spaced by step
+func GenerateBars(start time.Time, step time.Duration, n int) []Bar {
+	bars := make([]Bar, n)
+	price := 100.0
+	for i := range bars {
+		open := price
+		price += float64(i%5) - 2
+		bars[i] = Bar{
+			Time:   start.Add(time.Duration(i) * step).Unix(),
+			Open:   open,
+			High:   max(open, price) + 1,
+			Low:    min(open, price) - 1,
+			Close:  price,
+			Volume: float64(1000 + i),
+		}
+	}
+	return bars
+}
+
+// Message is a message received from a client
+type Message struct {
+	Method string        `json:"m"`
+	Params []interface{} `json:"p"`
+}
+
+// ParamString returns parameter i as a string, or "" if it is missing or not a string
+func (m Message) ParamString(i int) string {
+	if i < len(m.Params) {
+		if s, ok := m.Params[i].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithHeartbeatInterval sets how often the server sends ~h~ heartbeats, 0 disables them
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.heartbeatInterval = interval
+	}
+}
+
+// WithoutHello stops the server from sending the session info packet after the handshake
+func WithoutHello() Option {
+	return func(s *Server) {
+		s.sendHello = false
+	}
+}
+
+// WithMaxBars limits a series snapshot to the newest n bars, like the bar
+// limit of a TradingView plan; older bars are served by request_more_data.
+// 0 disables the limit.
+func WithMaxBars(n int) Option {
+	return func(s *Server) {
+		s.maxBars = n
+	}
+}
+
+// WithBars sets the bars served for symbol
+func WithBars(symbol string, bars []Bar) Option {
+	return func(s *Server) {
+		s.bars[symbol] = append([]Bar(nil), bars...)
+	}
+}
+
+// WithQuote sets the quote values served for symbol
+func WithQuote(symbol string, values map[string]interface{}) Option {
+	return func(s *Server) {
+		s.quotes[symbol] = values
+	}
+}
+
+// Server is a fake TradingView WebSocket server
+type Server struct {
+	URL string // ws:// URL to pass to the client
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	heartbeatInterval time.Duration
+	sendHello         bool
+	maxBars           int
+
+	mu                sync.Mutex
+	conns             map[*conn]struct{}
+	received          []Message
+	bars              map[string][]Bar
+	quotes            map[string]map[string]interface{}
+	rejectedSymbols   map[string]bool
+	responseDelay     time.Duration
+	rejectConnections bool
+	connections       int
+	heartbeatsAcked   int
+	changed           chan struct{} // closed whenever the recorded state changes
+}
+
+// NewServer starts a fake server; call Close when done
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		heartbeatInterval: 10 * time.Second,
+		sendHello:         true,
+		conns:             make(map[*conn]struct{}),
+		bars:              make(map[string][]Bar),
+		quotes:            make(map[string]map[string]interface{}),
+		rejectedSymbols:   make(map[string]bool),
+		changed:           make(chan struct{}),
+	}
+	// Clients send the tradingview.com Origin header
+	s.upgrader.CheckOrigin = func(*http.Request) bool { return true }
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = "ws" + strings.TrimPrefix(s.httpServer.URL, "http")
+	return s
+}
+
+// Close drops all connections and shuts the server down
+func (s *Server) Close() {
+	s.DropConnections()
+	s.httpServer.Close()
+}
+
+// SetBars replaces the bars served for symbol
+func (s *Server) SetBars(symbol string, bars []Bar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bars[symbol] = append([]Bar(nil), bars...)
+}
+
+// SetQuote replaces the quote values served for symbol
+func (s *Server) SetQuote(symbol string, values map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes[symbol] = values
+}
+
+// RejectSymbol makes resolve_symbol answer with symbol_error for symbol
+func (s *Server) RejectSymbol(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectedSymbols[symbol] = true
+}
+
+// SetResponseDelay delays every scripted response by d
+func (s *Server) SetResponseDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responseDelay = d
+}
+
+// SetRejectConnections makes new WebSocket upgrades fail while reject is true
+func (s *Server) SetRejectConnections(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectConnections = reject
+}
+
+// DropConnections abruptly closes every open connection without a close frame
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.ws.Close()
+	}
+}
+
+// Connections returns how many connections the server has accepted in total
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// HeartbeatsAcked returns how many heartbeats clients have echoed back
+func (s *Server) HeartbeatsAcked() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heartbeatsAcked
+}
+
+// Received returns every message received from clients, in order
+func (s *Server) Received() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.received...)
+}
+
+// Messages returns the received messages with the given method
+func (s *Server) Messages(method string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []Message
+	for _, m := range s.received {
+		if m.Method == method {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// WaitForMessages blocks until n messages with method have been received
+func (s *Server) WaitForMessages(ctx context.Context, method string, n int) ([]Message, error) {
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if messages := s.Messages(method); len(messages) >= n {
+			return messages, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for %d %s messages: %w", n, method, ctx.Err())
+		}
+	}
+}
+
+// WaitForConnections blocks until the server has accepted n connections in total
+func (s *Server) WaitForConnections(ctx context.Context, n int) error {
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		count := s.connections
+		s.mu.Unlock()
+
+		if count >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d connections: %w", n, ctx.Err())
+		}
+	}
+}
+
+// Send writes raw payloads, framed, to every open connection
+func (s *Server) Send(payloads ...string) {
+	for _, c := range s.openConns() {
+		c.send(payloads...)
+	}
+}
+
+// SendRaw writes frame unframed to every open connection, e.g. to test how
+// clients handle corrupted frames
+func (s *Server) SendRaw(frame string) {
+	for _, c := range s.openConns() {
+		c.sendRaw(frame)
+	}
+}
+
+// PushBar appends or updates the last bar of symbol and sends a du update to
+// every chart series streaming it
+func (s *Server) PushBar(symbol string, bar Bar) {
+	s.mu.Lock()
+	bars := s.bars[symbol]
+	if n := len(bars); n > 0 && bars[n-1].Time == bar.Time {
+		bars[n-1] = bar
+	} else {
+		bars = append(bars, bar)
+	}
+	s.bars[symbol] = bars
+
+	type update struct {
+		c       *conn
+		payload string
+	}
+	var updates []update
+	for c := range s.conns {
+		for sessionID, chart := range c.charts {
+			for seriesID, ser := range chart.series {
+				if ser.symbol != symbol {
+					continue
+				}
+				index := len(ser.bars)
+				if index > 0 && ser.bars[index-1].Time == bar.Time {
+					index--
+					ser.bars[index] = bar
+				} else {
+					ser.bars = append(ser.bars, bar)
+				}
+				payload := encodeMessage("du", sessionID, map[string]interface{}{
+					seriesID: map[string]interface{}{
+						"s":   []interface{}{map[string]interface{}{"i": index, "v": bar.values()}},
+						"ns":  map[string]interface{}{"d": "", "indexes": "nochange"},
+						"t":   ser.turnaround,
+						"lbs": map[string]interface{}{"bar_close_time": bar.Time + ser.step},
+					},
+				})
+				updates = append(updates, update{c, payload})
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, u := range updates {
+		u.c.send(u.payload)
+	}
+}
+
+// PushQuote updates the quote values of symbol and sends a qsd update to
+// every quote session containing it
+func (s *Server) PushQuote(symbol string, values map[string]interface{}) {
+	s.mu.Lock()
+	merged := make(map[string]interface{})
+	for k, v := range s.quotes[symbol] {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	s.quotes[symbol] = merged
+
+	type update struct {
+		c       *conn
+		payload string
+	}
+	var updates []update
+	for c := range s.conns {
+		for sessionID, names := range c.quotes {
+			for _, name := range names {
+				if parseSymbol(name) == symbol {
+					updates = append(updates, update{c, encodeMessage("qsd", sessionID, map[string]interface{}{
+						"n": name,
+						"s": "ok",
+						"v": values,
+					})})
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, u := range updates {
+		u.c.send(u.payload)
+	}
+}
+
+func (s *Server) openConns() []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// notifyLocked wakes up waiters; s.mu must be held
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	reject := s.rejectConnections
+	s.mu.Unlock()
+	if reject {
+		http.Error(w, "connection rejected", http.StatusServiceUnavailable)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &conn{
+		ws:     ws,
+		srv:    s,
+		done:   make(chan struct{}),
+		charts: make(map[string]*chartSession),
+		quotes: make(map[string][]string),
+	}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.connections++
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer func() {
+		close(c.done)
+		ws.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.notifyLocked()
+		s.mu.Unlock()
+	}()
+
+	if s.sendHello {
+		now := time.Now()
+		c.send(fmt.Sprintf(`{"session_id":"<0.%d.1>_tvwstest","timestamp":%d,"timestampMs":%d,"release":"tvwstest","studies_metadata_hash":"tvwstest","auth_scheme_vsn":2,"protocol":"json","javastudies":["3.66"]}`,
+			s.Connections(), now.Unix(), now.UnixMilli()))
+	}
+	if s.heartbeatInterval > 0 {
+		go c.heartbeats(s.heartbeatInterval)
+	}
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		payloads, err := decodeFrame(string(data))
+		if err != nil {
+			c.send(encodeMessage("protocol_error", err.Error()))
+			continue
+		}
+		for _, payload := range payloads {
+			if strings.HasPrefix(payload, "~h~") {
+				s.mu.Lock()
+				s.heartbeatsAcked++
+				s.notifyLocked()
+				s.mu.Unlock()
+				continue
+			}
+
+			var msg Message
+			if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+				c.send(encodeMessage("protocol_error", "wrong data"))
+				continue
+			}
+
+			s.mu.Lock()
+			s.received = append(s.received, msg)
+			s.notifyLocked()
+			delay := s.responseDelay
+			s.mu.Unlock()
+
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			c.handle(msg)
+		}
+	}
+}
+
+// parseSymbol extracts the symbol from a plain or ={...} descriptor string
+func parseSymbol(name string) string {
+	if !strings.HasPrefix(name, "=") {
+		return name
+	}
+	var descriptor struct {
+		Symbol string `json:"symbol"`
+	}
+	if err := json.Unmarshal([]byte(name[1:]), &descriptor); err != nil {
+		return name
+	}
+	return descriptor.Symbol
+}
@@ -0,0 +1,154 @@
+package tvwstest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testSymbol = "BINANCE:BTCUSDT"
+
+// dial connects to srv and returns the connection
+func dial(t *testing.T, srv *Server) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// sendMessage writes a {"m":...,"p":[...]} message in its own frame
+func sendMessage(t *testing.T, ws *websocket.Conn, method string, params ...interface{}) {
+	t.Helper()
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(encodeFrame(encodeMessage(method, params...)))); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+}
+
+// readUntil returns the first payload accepted by match
+func readUntil(t *testing.T, ws *websocket.Conn, match func(payload string) bool) string {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v", err)
+		}
+		payloads, err := decodeFrame(string(data))
+		if err != nil {
+			t.Fatalf("decodeFrame() error = %v", err)
+		}
+		for _, payload := range payloads {
+			if match(payload) {
+				return payload
+			}
+		}
+	}
+}
+
+// isMethod matches payloads of the given method
+func isMethod(method string) func(string) bool {
+	return func(payload string) bool {
+		var msg Message
+		return json.Unmarshal([]byte(payload), &msg) == nil && msg.Method == method
+	}
+}
+
+func TestServerHandshake(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ws := dial(t, srv)
+	hello := readUntil(t, ws, func(string) bool { return true })
+
+	var info struct {
+		SessionID string `json:"session_id"`
+		Release   string `json:"release"`
+	}
+	if err := json.Unmarshal([]byte(hello), &info); err != nil || info.Release != "tvwstest" || info.SessionID == "" {
+		t.Errorf("session info = %q, want a tvwstest session", hello)
+	}
+	if got := srv.Connections(); got != 1 {
+		t.Errorf("Connections() = %d, want 1", got)
+	}
+}
+
+func TestServerHeartbeats(t *testing.T) {
+	srv := NewServer(WithHeartbeatInterval(20 * time.Millisecond))
+	defer srv.Close()
+
+	ws := dial(t, srv)
+	heartbeat := readUntil(t, ws, func(payload string) bool { return strings.HasPrefix(payload, "~h~") })
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(encodeFrame(heartbeat))); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.HeartbeatsAcked() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("heartbeat reply was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerPushBar(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := GenerateBars(start, time.Minute, 5)
+	srv := NewServer(WithBars(testSymbol, bars))
+	defer srv.Close()
+
+	ws := dial(t, srv)
+	sendMessage(t, ws, "chart_create_session", "cs_test", "")
+	sendMessage(t, ws, "resolve_symbol", "cs_test", "sds_sym_1", `={"symbol":"`+testSymbol+`"}`)
+	sendMessage(t, ws, "create_series", "cs_test", "sds_1", "s1", "sds_sym_1", "1", 5, "")
+	readUntil(t, ws, isMethod("timescale_update"))
+
+	next := Bar{Time: bars[4].Time + 60, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 3}
+	srv.PushBar(testSymbol, next)
+
+	var du struct {
+		Params []json.RawMessage `json:"p"`
+	}
+	if err := json.Unmarshal([]byte(readUntil(t, ws, isMethod("du"))), &du); err != nil || len(du.Params) != 2 {
+		t.Fatalf("du = %+v, %v", du, err)
+	}
+	var data map[string]struct {
+		S []struct {
+			I int       `json:"i"`
+			V []float64 `json:"v"`
+		} `json:"s"`
+	}
+	if err := json.Unmarshal(du.Params[1], &data); err != nil {
+		t.Fatal(err)
+	}
+	points := data["sds_1"].S
+	if len(points) != 1 || points[0].I != 5 || points[0].V[0] != float64(next.Time) || points[0].V[4] != next.Close {
+		t.Errorf("du points = %+v, want bar %d at index 5", points, next.Time)
+	}
+}
+
+func TestServerIgnoresMissingParams(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ws := dial(t, srv)
+	sendMessage(t, ws, "quote_add_symbols")
+	sendMessage(t, ws, "quote_remove_symbols")
+	sendMessage(t, ws, "quote_create_session", "qs_test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := srv.WaitForMessages(ctx, "quote_create_session", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(srv.Messages("quote_add_symbols")); got != 1 {
+		t.Errorf("Messages(quote_add_symbols) = %d, want 1", got)
+	}
+}
@@ -3,6 +3,8 @@ package tvwsclient
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Option represents a client option
@@ -220,6 +222,12 @@ type Source2Info struct {
 type TimescaleUpdateMessage struct {
 	ChartSessionID string              `json:"0"`
 	Data           TimescaleUpdateData `json:"1"`
+	Timezone       string              `json:"-"` // timezone of the chart session, set by the client
+}
+
+// Location returns the timezone the bars are aligned to, UTC when unknown
+func (m *TimescaleUpdateMessage) Location() *time.Location {
+	return timezoneLocation(m.Timezone)
 }
 
 type TimescaleUpdateData struct {
@@ -238,13 +246,14 @@ type TimescaleUpdateData struct {
 			BarCloseTime int64 `json:"bar_close_time"`
 		} `json:"lbs"`
 	} `json:"sds_1"`
-	Index     int     `json:"index"`
-	Zoffset   int     `json:"zoffset"`
-	Changes   []int64 `json:"changes"`
-	Marks     [][]int `json:"marks"`
-	IndexDiff []any   `json:"index_diff"`
-	T         int64   `json:"t"`
-	TMs       int64   `json:"t_ms"`
+	Series    map[string]SeriesData `json:"-"` // every sds_N key, including sds_1
+	Index     int                   `json:"index"`
+	Zoffset   int                   `json:"zoffset"`
+	Changes   []int64               `json:"changes"`
+	Marks     [][]int               `json:"marks"`
+	IndexDiff []any                 `json:"index_diff"`
+	T         int64                 `json:"t"`
+	TMs       int64                 `json:"t_ms"`
 }
 
 // QuoteCompletedMessage represents the quote_completed message
@@ -268,6 +277,12 @@ type SeriesCompletedMessage struct {
 type DuMessage struct {
 	ChartSessionID string `json:"0"` // "cs_lZqOBD1Jtvjb"
 	Data           DuData `json:"1"` // The nested data object
+	Timezone       string `json:"-"` // timezone of the chart session, set by the client
+}
+
+// Location returns the timezone the bars are aligned to, UTC when unknown
+func (m *DuMessage) Location() *time.Location {
+	return timezoneLocation(m.Timezone)
 }
 
 type DuData struct {
@@ -282,6 +297,84 @@ type DuData struct {
 		S []DuSeriesData `json:"s"`
 		T string         `json:"t"` // Series set (e.g., "s1")
 	} `json:"sds_1"`
+	Series map[string]SeriesData `json:"-"` // every sds_N key, including sds_1
+}
+
+// SeriesData is the payload of one series in a timescale_update or du message,
+// keyed by its series ID (sds_1, sds_2, ...)
+type SeriesData struct {
+	Node string         `json:"node,omitempty"`
+	S    []DuSeriesData `json:"s"`
+	NS   struct {
+		D       string      `json:"d"`
+		Indexes interface{} `json:"indexes"` // Can be string "nochange" or array []
+	} `json:"ns"`
+	T   string `json:"t"` // Series set (e.g., "s1")
+	Lbs struct {
+		BarCloseTime int64 `json:"bar_close_time"`
+	} `json:"lbs"`
+}
+
+// UnmarshalJSON decodes the data and collects all sds_N series keys
+func (d *TimescaleUpdateData) UnmarshalJSON(data []byte) error {
+	type plain TimescaleUpdateData
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	series, err := decodeSeriesKeys(data)
+	if err != nil {
+		return err
+	}
+	d.Series = series
+	return nil
+}
+
+// UnmarshalJSON decodes the data and collects all sds_N series keys
+func (d *DuData) UnmarshalJSON(data []byte) error {
+	type plain DuData
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	series, err := decodeSeriesKeys(data)
+	if err != nil {
+		return err
+	}
+	d.Series = series
+	return nil
+}
+
+// decodeSeriesKeys decodes every sds_N key of a chart data object
+func decodeSeriesKeys(data []byte) (map[string]SeriesData, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	series := make(map[string]SeriesData)
+	for key, value := range raw {
+		if !isSeriesID(key) {
+			continue
+		}
+		var s SeriesData
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, fmt.Errorf("series %s: %w", key, err)
+		}
+		series[key] = s
+	}
+	return series, nil
+}
+
+// isSeriesID reports whether key is a series ID such as sds_2
+func isSeriesID(key string) bool {
+	n, ok := strings.CutPrefix(key, "sds_")
+	if !ok || n == "" {
+		return false
+	}
+	for _, r := range n {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 type DuSeriesData struct {
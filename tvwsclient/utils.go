@@ -1,7 +1,6 @@
 package tvwsclient
 
 import (
-	"fmt"
 	"math/rand"
 )
 
@@ -16,6 +15,5 @@ func GenerateSession(prefix string) string {
 }
 
 func wrappedMessage(msg string) string {
-	wrappedMsg := fmt.Sprintf("~m~%d~m~%s", len(msg), msg)
-	return wrappedMsg
+	return EncodeFrame(msg)
 }
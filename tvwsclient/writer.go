@@ -0,0 +1,250 @@
+package tvwsclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writePriority orders queued outbound messages; higher priorities are written first
+type writePriority int
+
+const (
+	priorityNormal    writePriority = iota
+	priorityHigh                    // connection setup such as set_auth_token
+	priorityHeartbeat               // heartbeat replies, never rate limited
+	priorityCount
+)
+
+// Flow control defaults
+const (
+	defaultWriteQueueSize = 256
+	defaultRateLimit      = 10 // messages per second
+	defaultRateBurst      = 10
+	flushTimeout          = 5 * time.Second
+)
+
+// outboundMessage is a framed payload waiting for the writer goroutine
+type outboundMessage struct {
+	payload   string
+	operation string
+	priority  writePriority
+	conn      *websocket.Conn // connection the message was queued for
+	result    chan error
+}
+
+// writeQueue is a bounded priority queue drained by Client.writeLoop
+type writeQueue struct {
+	mu       sync.Mutex
+	queues   [priorityCount][]*outboundMessage
+	size     int // queued messages that count against capacity
+	capacity int
+	closing  bool
+	ready    chan struct{} // signalled when a message is queued or the queue closes
+	stopped  chan struct{} // closed when the writer goroutine exits
+}
+
+func newWriteQueue(capacity int) *writeQueue {
+	return &writeQueue{
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// push queues msg. Heartbeats are always accepted; other messages fail with
+// ErrRateLimitExceeded when the queue is full.
+func (q *writeQueue) push(msg *outboundMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return WrapConnectionError(msg.operation, ErrConnectionClosed)
+	}
+	if msg.priority != priorityHeartbeat {
+		if q.size >= q.capacity {
+			return NewTradingViewError(msg.operation, ErrCodeRateLimit,
+				fmt.Sprintf("write queue full (%d messages)", q.capacity), ErrRateLimitExceeded)
+		}
+		q.size++
+	}
+	q.queues[msg.priority] = append(q.queues[msg.priority], msg)
+	q.signal()
+	return nil
+}
+
+// pop returns the highest priority message, or nil when the queue is empty
+func (q *writeQueue) pop() (*outboundMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for p := priorityCount - 1; p >= 0; p-- {
+		if len(q.queues[p]) == 0 {
+			continue
+		}
+		msg := q.queues[p][0]
+		q.queues[p] = q.queues[p][1:]
+		if msg.priority != priorityHeartbeat {
+			q.size--
+		}
+		return msg, q.closing
+	}
+	return nil, q.closing
+}
+
+// waiting reports whether any message with at least priority p is queued
+func (q *writeQueue) waiting(p writePriority) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ; p < priorityCount; p++ {
+		if len(q.queues[p]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *writeQueue) isClosing() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closing
+}
+
+// close stops accepting messages; the writer flushes what is queued and exits
+func (q *writeQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closing = true
+	q.signal()
+}
+
+func (q *writeQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// tokenBucket limits the outbound message rate; a zero rate disables it
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// delay refills the bucket and returns how long until a token is available
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take consumes a token
+func (b *tokenBucket) take() {
+	if b.rate > 0 {
+		b.tokens--
+	}
+}
+
+// enqueue queues a framed payload for the connection that is current now
+func (c *Client) enqueue(payload, operation string, priority writePriority) error {
+	c.mu.Lock()
+	conn := c.ws
+	c.mu.Unlock()
+	if conn == nil {
+		return WrapConnectionError(operation, ErrConnectionClosed)
+	}
+
+	msg := &outboundMessage{
+		payload:   payload,
+		operation: operation,
+		priority:  priority,
+		conn:      conn,
+		result:    make(chan error, 1),
+	}
+	if err := c.writes.push(msg); err != nil {
+		return err
+	}
+	return <-msg.result
+}
+
+// writeLoop is the only goroutine writing data frames to the connection
+func (c *Client) writeLoop() {
+	defer close(c.writes.stopped)
+
+	limiter := newTokenBucket(c.rateLimit, c.rateBurst)
+	var flushDeadline time.Time
+	for {
+		closing := c.writes.isClosing()
+
+		// Wait for a token unless a heartbeat can jump the queue
+		if !closing && c.writes.waiting(priorityNormal) && !c.writes.waiting(priorityHeartbeat) {
+			if wait := limiter.delay(time.Now()); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-c.writes.ready:
+					timer.Stop()
+				}
+				continue
+			}
+		}
+
+		msg, closing := c.writes.pop()
+		if msg == nil {
+			if closing {
+				return
+			}
+			<-c.writes.ready
+			continue
+		}
+
+		if closing {
+			// Flush what is left without rate limiting, but give up eventually
+			if flushDeadline.IsZero() {
+				flushDeadline = time.Now().Add(flushTimeout)
+			}
+			if time.Now().After(flushDeadline) {
+				msg.result <- WrapConnectionError(msg.operation, ErrConnectionClosed)
+				continue
+			}
+		} else if msg.priority != priorityHeartbeat {
+			limiter.take()
+		}
+
+		msg.result <- c.writeFrame(msg)
+	}
+}
+
+// writeFrame writes msg to its connection unless the connection was replaced
+func (c *Client) writeFrame(msg *outboundMessage) error {
+	c.mu.Lock()
+	current := c.ws
+	c.mu.Unlock()
+	if current != msg.conn {
+		return WrapConnectionError(msg.operation, ErrConnectionClosed)
+	}
+
+	c.logger.Debug("Send Message", "message", msg.payload)
+	if err := msg.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return WrapConnectionError(msg.operation, err)
+	}
+	if err := msg.conn.WriteMessage(websocket.TextMessage, []byte(msg.payload)); err != nil {
+		return WrapConnectionError(msg.operation, fmt.Errorf("error sending %s: %w", msg.operation, err))
+	}
+	return nil
+}
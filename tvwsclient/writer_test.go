@@ -0,0 +1,125 @@
+package tvwsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iiiyu/tradingview-ws-client/tvwsclient/tvwstest"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10, 2)
+	b.last = now
+
+	for i := 0; i < 2; i++ {
+		if d := b.delay(now); d != 0 {
+			t.Fatalf("delay() within burst = %v, want 0", d)
+		}
+		b.take()
+	}
+	if d := b.delay(now); d != 100*time.Millisecond {
+		t.Errorf("delay() after burst = %v, want 100ms", d)
+	}
+	if d := b.delay(now.Add(100 * time.Millisecond)); d != 0 {
+		t.Errorf("delay() after refill = %v, want 0", d)
+	}
+	if d := newTokenBucket(0, 0).delay(now); d != 0 {
+		t.Errorf("delay() without limit = %v, want 0", d)
+	}
+}
+
+func TestWriteQueue(t *testing.T) {
+	q := newWriteQueue(2)
+	push := func(payload string, priority writePriority) error {
+		return q.push(&outboundMessage{payload: payload, operation: payload, priority: priority})
+	}
+
+	if err := push("normal", priorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	if err := push("auth", priorityHigh); err != nil {
+		t.Fatal(err)
+	}
+	if err := push("overflow", priorityNormal); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Fatalf("push() on a full queue error = %v, want ErrRateLimitExceeded", err)
+	}
+	if err := push("heartbeat", priorityHeartbeat); err != nil {
+		t.Fatalf("push() heartbeat on a full queue error = %v", err)
+	}
+
+	for _, want := range []string{"heartbeat", "auth", "normal"} {
+		msg, _ := q.pop()
+		if msg == nil || msg.payload != want {
+			t.Fatalf("pop() = %+v, want %s", msg, want)
+		}
+	}
+
+	q.close()
+	if err := push("late", priorityNormal); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("push() after close error = %v, want ErrConnectionClosed", err)
+	}
+}
+
+func TestConcurrentSends(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+	client := newTestClient(t, srv, WithReconnectPolicy(NewConstantBackoff(10*time.Millisecond, 0)))
+
+	const sends = 30
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := SendChartCreateSessionMessage(client, fmt.Sprintf("cs_%d", i)); err != nil {
+				t.Errorf("SendChartCreateSessionMessage() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := srv.WaitForMessages(ctx, "chart_create_session", sends); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseFlushesWriteQueue(t *testing.T) {
+	srv := tvwstest.NewServer()
+	defer srv.Close()
+
+	client := newTestClient(t, srv, WithRateLimit(1, 1))
+
+	// The init messages used the only token, so these wait in the queue
+	const sends = 5
+	errs := make(chan error, sends)
+	for i := 0; i < sends; i++ {
+		go func(i int) {
+			errs <- SendChartCreateSessionMessage(client, fmt.Sprintf("cs_%d", i))
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	client.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close() took %v, want the flush to skip the rate limit", elapsed)
+	}
+	for i := 0; i < sends; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("send error = %v, want queued messages to be flushed", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := srv.WaitForMessages(ctx, "chart_create_session", sends); err != nil {
+		t.Fatal(err)
+	}
+}